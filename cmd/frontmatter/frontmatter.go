@@ -20,11 +20,11 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	// 3rd Party packages
 	"github.com/BurntSushi/toml"
@@ -40,9 +40,72 @@ import (
 var (
 	description = `
 %s extracts a front matter from a Markdown file. If no front matter is present then an empty file is returned. Note %s doesn’t process the data extracted. It returns it unprocessed. Other tools can be used to process the front matter appropriately. By default %s reads from standard in and writes to standard out. This makes it very suitable for pipeline processing or for passing JSON formatted front matter back to mkpage for integration into the templates processed.
+
+VALIDATING
+
+With -validate SCHEMA the front matter of the documents named on the
+command line (Markdown, Mmark and Fountain files, directories are
+searched) or read from input is checked against SCHEMA. SCHEMA is a
+JSON, TOML or YAML file holding a JSON Schema subset ("required"
+and "properties" with "type", "enum" and "format") or a simpler
+field specification using "fields" in place of "properties". The
+type "date" (or format "date") expects a date like 2006-01-02,
+2006-01-02 15:04:05, 2006-01-02 15:04:05 -0700 or an RFC 3339
+timestamp (2006-01-02T15:04:05Z), the dates read when building a
+site. If SCHEMA is a site configuration (or a directory holding one)
+its "frontmatter" table is used, a site configuration without one is
+an error, e.g.
+
+    [frontmatter]
+    required = [ "title", "date" ]
+
+    [frontmatter.fields]
+    title = { type = "string" }
+    date = { type = "date" }
+    draft = { type = "boolean" }
+    type = { enum = [ "post", "article", "homepage" ] }
+    markup = { enum = [ "mmark", "gomarkdown", "fountain" ] }
+
+Each violation is written with the file and field name, the exit
+code is 1 if any were found.
+
+EDITING
+
+The front matter of documents can be changed with the options below.
+Documents named on the command line (directories are searched) are
+rewritten in place, otherwise the document is read from input and
+written to output. The body of the document is left untouched.
+
++ -set takes a key=value pair and can be repeated, "true" and
+  "false" are booleans and integers are numbers unless quoted (e.g.
+  year='"2020"'), dotted keys reach into tables (e.g.
+  gomarkdown.Footnotes=true), the value may hold commas
++ -delete takes a comma delimited list of keys
++ -rename takes comma delimited old=new pairs applied in the order
+  given, e.g. a=b,b=c renames a to c
++ -convert rewrites the front matter as toml (fenced by +++),
+  mmark (TOML fenced by %%%%%%), yaml (fenced by ---) or json
+
+Renames are applied before deletes and deletes before sets. A document
+is only rewritten if its front matter changes, when it is the keys are
+written in sorted order. New front matter is JSON unless -convert is
+given.
 `
 
 	examples = `
+Add draft = false and rename author to creator in every post
+
+    %s -set draft=false -set tags=news,events -rename author=creator content/posts
+
+Convert a document's YAML front matter to TOML
+
+    %s -convert toml -i article.md -o article-toml.md
+
+Check the front matter of every document in the content directory
+against a schema, each problem is reported with the file and field.
+
+    %s -validate schema.json content
+
 Extract a front matter from article.md.
 
     cat article.md | %s
@@ -66,9 +129,163 @@ Will also do the same.
 	generateManPage  bool
 
 	// App Options
-	jsonFormat bool
+	jsonFormat  bool
+	schemaFName string
+	setPair     string
+	setPairs    []string
+	deleteKeys  string
+	renameKeys  string
+	convertTo   string
 )
 
+// validate checks the front matter of the documents named in args
+// (files or directories) or read from input against the schema,
+// reporting each violation. It returns an exit code.
+func validate(app *cli.Cli, args []string) int {
+	fName := schemaFName
+	if info, err := os.Stat(fName); err == nil && info.IsDir() {
+		fName = mkpage.FindConfig(schemaFName)
+		if fName == "" {
+			cli.OnError(app.Eout, fmt.Errorf("Can't find a site configuration in %s", schemaFName), quiet)
+			return 1
+		}
+	}
+	schema, err := mkpage.ReadSchema(fName)
+	if err != nil {
+		cli.OnError(app.Eout, err, quiet)
+		return 1
+	}
+	violations := []*mkpage.Violation{}
+	if len(args) == 0 {
+		buf, err := ioutil.ReadAll(app.In)
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		name := inputFName
+		if name == "" || name == "-" {
+			name = "stdin"
+		}
+		configType, frontMatterSrc, _, err := mkpage.SplitFrontMatterWithError(buf)
+		if err != nil {
+			violations = append(violations, &mkpage.Violation{File: name, Message: err.Error()})
+		} else if frontMatter, err := mkpage.ProcessorConfig(configType, frontMatterSrc); err != nil {
+			violations = append(violations, &mkpage.Violation{File: name, Message: fmt.Sprintf("Can't read front matter, %s", err)})
+		} else {
+			violations = append(violations, schema.Validate(name, frontMatter)...)
+		}
+	}
+	for _, p := range args {
+		l, err := schema.ValidatePath(p)
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		violations = append(violations, l...)
+	}
+	for _, v := range violations {
+		fmt.Fprintf(app.Out, "%s\n", v)
+	}
+	if len(violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// editDocuments applies -set, -delete, -rename and -convert to the
+// documents named in args (files or directories) rewriting them in
+// place, or from input to output if args is empty. It returns an
+// exit code.
+func editDocuments(app *cli.Cli, args []string) int {
+	edit := &mkpage.FrontMatterEdit{Set: map[string]interface{}{}}
+	for _, pair := range setPairs {
+		if err := mkpage.SetConfigPair(edit.Set, pair); err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+	}
+	for _, key := range strings.Split(deleteKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			edit.Delete = append(edit.Delete, key)
+		}
+	}
+	for _, pair := range strings.Split(renameKeys, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			cli.OnError(app.Eout, fmt.Errorf("Can't read rename %q, expected old=new", pair), quiet)
+			return 1
+		}
+		edit.Rename = append(edit.Rename, mkpage.FrontMatterRename{
+			Old: strings.TrimSpace(kv[0]),
+			New: strings.TrimSpace(kv[1]),
+		})
+	}
+	switch convertTo {
+	case "", mkpage.FrontMatterTOML, mkpage.FrontMatterMmark, mkpage.FrontMatterYAML, mkpage.FrontMatterJSON:
+		edit.Format = convertTo
+	default:
+		cli.OnError(app.Eout, fmt.Errorf("Can't convert to %q, expected toml, mmark, yaml or json", convertTo), quiet)
+		return 1
+	}
+
+	if len(args) == 0 {
+		buf, err := ioutil.ReadAll(app.In)
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		src, _, err := edit.Apply(buf)
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		fmt.Fprintf(app.Out, "%s", src)
+		return 0
+	}
+
+	failed := 0
+	for _, p := range args {
+		if _, err := os.Stat(p); err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		err := mkpage.Walk(p, func(fName string, info os.FileInfo) bool {
+			return info != nil && info.IsDir() == false && mkpage.IsDocument(fName) && mkpage.IsDotPath(fName) == false
+		}, func(fName string, info os.FileInfo) error {
+			buf, err := ioutil.ReadFile(fName)
+			if err != nil {
+				return err
+			}
+			src, changed, err := edit.Apply(buf)
+			if err != nil {
+				fmt.Fprintf(app.Eout, "%s, %s\n", fName, err)
+				failed++
+				return nil
+			}
+			if changed {
+				if err := ioutil.WriteFile(fName, src, info.Mode().Perm()); err != nil {
+					return err
+				}
+				if quiet == false {
+					fmt.Fprintf(app.Out, "Updated %s\n", fName)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
 func main() {
 	app := cli.NewCli(mkpage.Version)
 	appName := app.AppName()
@@ -87,13 +304,20 @@ func main() {
 	// Configuration and command line interation
 	app.AddHelp("license", []byte(fmt.Sprintf(mkpage.LicenseText, appName, mkpage.Version)))
 	app.AddHelp("description", []byte(fmt.Sprintf(description, appName, appName, appName)))
-	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName)))
+	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName, appName, appName, appName)))
 
 	// App options
 	app.BoolVar(&jsonFormat, "j,json", false, "output as JSON")
+	app.StringVar(&schemaFName, "validate", "", "validate front matter against a schema file (or the site configuration in a directory)")
+	app.StringVar(&setPair, "set", "", "key=value pair to set, e.g. draft=false, can be repeated")
+	app.StringVar(&deleteKeys, "delete", "", "comma delimited keys to remove")
+	app.StringVar(&renameKeys, "rename", "", "comma delimited old=new key names to rename, e.g. author=creator")
+	app.StringVar(&convertTo, "convert", "", "convert front matter to toml, mmark, yaml or json")
 
 	app.Parse()
 	args := app.Args()
+	// NOTE: -set can be repeated, the flag package keeps only the last
+	setPairs = mkpage.FlagValues(os.Args[1:], "set")
 
 	// Setup IO
 	var err error
@@ -135,30 +359,35 @@ func main() {
 		os.Exit(0)
 	}
 
+	if schemaFName != "" {
+		os.Exit(validate(app, args))
+	}
+	if len(setPairs) > 0 || deleteKeys != "" || renameKeys != "" || convertTo != "" {
+		os.Exit(editDocuments(app, args))
+	}
+
 	//NOTE: read input and pass front matter to output.
 	buf, err := ioutil.ReadAll(app.In)
 	if err != nil {
 		fmt.Fprintf(app.Eout, "%s", err)
 		os.Exit(1)
 	}
-	_, frontMatterSrc, _ := mkpage.SplitFrontMatter(buf)
+	configType, frontMatterSrc, _, err := mkpage.SplitFrontMatterWithError(buf)
+	if err != nil {
+		fmt.Fprintf(app.Eout, "%s\n", err)
+		os.Exit(1)
+	}
 	if len(frontMatterSrc) > 0 {
 		if jsonFormat {
 			obj := make(map[string]interface{})
-			switch {
-			case bytes.HasPrefix(buf, []byte("+++\n")):
-				// Make sure we have valid Toml
-				if err := toml.Unmarshal(frontMatterSrc, &obj); err != nil {
-					fmt.Fprintf(app.Eout, "Toml error: %s", err)
-					os.Exit(1)
-				}
-			case bytes.HasPrefix(buf, []byte("%%%\n")):
+			switch configType {
+			case mkpage.ConfigIsTOML:
 				// Make sure we have valid Toml
 				if err := toml.Unmarshal(frontMatterSrc, &obj); err != nil {
 					fmt.Fprintf(app.Eout, "Toml error: %s", err)
 					os.Exit(1)
 				}
-			case bytes.HasPrefix(buf, []byte("---\n")):
+			case mkpage.ConfigIsYAML:
 				if src, err := yaml.YAMLToJSON(frontMatterSrc); err != nil {
 					fmt.Fprintf(app.Eout, "Yaml to JSON error: %s", err)
 					os.Exit(1)
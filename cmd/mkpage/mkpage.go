@@ -25,6 +25,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/cli"
@@ -37,6 +38,79 @@ var (
 SYNOPSIS
 
 Using the key/value pairs populate the template(s) and render to stdout.
+
+BUILDING A SITE
+
+    %s [OPTIONS] -build [CONTENT_DIR [OUTPUT_DIR]] [KEY/VALUE DATA PAIRS]
+
+Walks CONTENT_DIR rendering each .md, .mmark, .fountain and .spmd
+file to HTML in OUTPUT_DIR, other files are copied unchanged (dot
+files, .tmpl files and the site configuration are skipped). The
+rendered document is available to the template as "content" along
+with the document's front matter (e.g. "title").
+
+Templates can also use "page" (or "Page") describing the document
+being rendered. It has the fields FrontMatter, Title, Content, Source,
+Output, RelRoot (e.g. "../" for blog/post.html), WordCount,
+ReadingTime (minutes), Headings (each with Level, ID and Text), Date,
+Lastmod, PublishDate and ExpiryDate (as time.Time) and Draft, e.g.
+{{ .page.RelRoot }}css/site.css or {{ .page.Date.Format "Jan 2, 2006" }}. The page is also available
+when rendering a single document named with "content", e.g.
+content=post.md.
+
+Templates are chosen in the following order
+
++ a "template" in the front matter (a filename or colon delimited list)
++ a page.tmpl in the document's directory or a parent up to CONTENT_DIR
++ the templates set with -templates, MKPAGE_TEMPLATES or configuration
++ the default page template
+
+Each file processed is reported along with a summary. CONTENT_DIR
+and OUTPUT_DIR default to "content" and "docs" from the site
+configuration.
+
+The inputs of every output file (the document, templates, files and
+URLs read for key/value pairs, each with a content hash) are recorded
+in OUTPUT_DIR/.mkpage-manifest.json. Later builds skip files whose
+inputs haven't changed, use -force to render everything. Files
+are rendered in parallel, -jobs sets how many at a time (e.g.
+-jobs 1 renders one file at a time).
+
+Documents with draft = true in their front matter, a publishDate
+in the future or an expiryDate in the past are excluded from the
+build (a page published by an earlier build is removed). Use
+-drafts and -future to include drafts and future documents when
+previewing a site.
+
+WATCHING FOR CHANGES
+
+With -watch the page is rendered then re-rendered whenever the
+templates (-templates, MKPAGE_TEMPLATES or the template filenames)
+or local files named in the key/value pairs (e.g. content=index.md)
+change. Use -o to rewrite an output file each time. With -build,
+CONTENT_DIR, the templates and data files are watched and each
+change triggers a build which only renders what changed. Files
+are polled for changes once a second, press ctrl-c to stop.
+
+CONFIGURATION
+
+Defaults can be read from a site configuration file named mkpage.toml,
+mkpage.json or mkpage.yaml in the current working directory (or
+the file or directory named by MKPAGE_CONFIG or -config). Values
+in a [mkpage] section take precedence over top level values.
+
++ templates - a colon delimited list (or array) of templates
++ data - a table of key/value data pairs, command line pairs override these
++ drafts, future - true to include drafts and future documents in a build
+
+Markup processor settings (e.g. "gomarkdown", "mmark" and "fountain"
+tables) are merged in the following order, later ones taking precedence:
+built-in defaults, site configuration, the document's front matter and
+finally the -set option. Nested tables are merged key by key, arrays
+and other values are replaced.
+
+Command line options and environment variables override the
+site configuration.
 `
 
 	examples = `
@@ -97,8 +171,111 @@ Golang's text/template docs can be found at
 	showTemplate   bool
 	codesnip       bool
 	codeType       string
+	configFName    string
+	configPairs    string
+	forceBuild     bool
+	buildJobs      int
+	buildDrafts    bool
+	buildFuture    bool
+	buildMode      bool
+	watch          bool
 )
 
+// watchInterval is how often sources are polled for changes with -watch
+const watchInterval = time.Second
+
+// buildSite renders CONTENT_DIR into OUTPUT_DIR reporting each file
+// processed and returns an exit code.
+func buildSite(app *cli.Cli, args []string, templateSources []string) int {
+	site := new(mkpage.Site)
+	site.ContentDir = mkpage.ConfigString("mkpage", "content")
+	site.OutputDir = mkpage.ConfigString("mkpage", "docs")
+	site.Templates = templateSources
+	site.Data = mkpage.ConfigData("mkpage")
+	site.Force = forceBuild
+	site.Jobs = buildJobs
+	if site.Jobs == 0 {
+		site.Jobs = mkpage.ConfigInt("mkpage", "jobs")
+	}
+	site.Drafts = buildDrafts || mkpage.ConfigBool("mkpage", "drafts")
+	site.Future = buildFuture || mkpage.ConfigBool("mkpage", "future")
+	dirs := []string{}
+	for i, arg := range args {
+		if strings.Contains(arg, "=") == true {
+			pair := strings.SplitN(arg, "=", 2)
+			if len(pair) != 2 {
+				fmt.Fprintf(app.Eout, "Can't read pair (%d) %s\n", i+1, arg)
+				return 1
+			}
+			site.Data[pair[0]] = pair[1]
+		} else {
+			dirs = append(dirs, arg)
+		}
+	}
+	if len(dirs) > 2 {
+		cli.OnError(app.Eout, fmt.Errorf("-build takes at most a CONTENT_DIR and OUTPUT_DIR, got %s", strings.Join(dirs, " ")), quiet)
+		return 1
+	}
+	if len(dirs) > 0 {
+		site.ContentDir = dirs[0]
+	}
+	if len(dirs) > 1 {
+		site.OutputDir = dirs[1]
+	}
+	if site.ContentDir == "" || site.OutputDir == "" {
+		cli.OnError(app.Eout, fmt.Errorf("-build requires a CONTENT_DIR and OUTPUT_DIR"), quiet)
+		return 1
+	}
+	build := func() int {
+		results, err := site.Build()
+		rendered, copied, skipped, excluded, failed := 0, 0, 0, 0, 0
+		for _, result := range results {
+			switch result.Action {
+			case mkpage.BuildRendered:
+				rendered++
+			case mkpage.BuildCopied:
+				copied++
+			case mkpage.BuildSkipped:
+				skipped++
+			case mkpage.BuildExcluded:
+				excluded++
+			default:
+				failed++
+			}
+			if result.Err != nil {
+				fmt.Fprintf(app.Eout, "%s\n", result)
+			} else if quiet == false && (watch == false || result.Action != mkpage.BuildSkipped) {
+				fmt.Fprintf(app.Out, "%s\n", result)
+			}
+		}
+		if quiet == false {
+			fmt.Fprintf(app.Out, "%d rendered, %d copied, %d skipped, %d excluded, %d failed\n", rendered, copied, skipped, excluded, failed)
+		}
+		if err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			return 1
+		}
+		return 0
+	}
+	if watch == false {
+		return build()
+	}
+
+	// Build then rebuild whenever the content, templates or data
+	// files change, the manifest limits a rebuild to what changed.
+	watcher := mkpage.NewWatcher(watchInterval)
+	watcher.Exclude = []string{site.OutputDir}
+	watcher.Add(site.ContentDir)
+	watcher.Add(site.Templates...)
+	watcher.Add(mkpage.DataFiles(site.Data)...)
+	build()
+	fmt.Fprintf(app.Eout, "Watching %s for changes, press ctrl-c to stop\n", site.ContentDir)
+	watcher.Watch(nil, func(changed []string) {
+		build()
+	})
+	return 0
+}
+
 func main() {
 	app := cli.NewCli(mkpage.Version)
 	appName := app.AppName()
@@ -108,11 +285,12 @@ func main() {
 
 	// Add Help docs
 	app.AddHelp("license", []byte(fmt.Sprintf(mkpage.LicenseText, appName, mkpage.Version)))
-	app.AddHelp("description", []byte(fmt.Sprintf(description)))
+	app.AddHelp("description", []byte(fmt.Sprintf(description, appName)))
 	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName)))
 
 	// Setup Environment variables
 	app.EnvStringVar(&templateFNames, "MKPAGE_TEMPLATES", "", "set the default template path")
+	app.EnvStringVar(&configFName, "MKPAGE_CONFIG", "", "set the site configuration file or directory")
 
 	// Standard Options
 	app.BoolVar(&showHelp, "h,help", false, "display help")
@@ -132,6 +310,14 @@ func main() {
 	app.StringVar(&templateFNames, "templates", "", "colon delimited list of templates to use")
 	app.BoolVar(&codesnip, "codesnip", false, "output just the code bocks")
 	app.StringVar(&codeType, "code", "", "outout just code blocks for language, e.g. shell or json")
+	app.StringVar(&configFName, "config", "", "read site configuration (mkpage.toml, mkpage.json or mkpage.yaml) from file or directory")
+	app.StringVar(&configPairs, "set", "", "comma delimited configuration overrides, e.g. gomarkdown.Footnotes=true")
+	app.BoolVar(&buildMode, "build", false, "render CONTENT_DIR into OUTPUT_DIR")
+	app.BoolVar(&forceBuild, "force", false, "with -build, render every file ignoring the previous build's manifest")
+	app.IntVar(&buildJobs, "jobs", 0, "with -build, the number of files to render in parallel (defaults to the number of CPUs)")
+	app.BoolVar(&buildDrafts, "drafts", false, "with -build, render documents marked draft = true")
+	app.BoolVar(&buildFuture, "future", false, "with -build, render documents with a publishDate in the future")
+	app.BoolVar(&watch, "watch", false, "re-render when the input, templates or data files change")
 
 	app.Parse()
 	args := app.Args()
@@ -158,6 +344,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Load site configuration, command line and environment take precedence
+	err := mkpage.LoadConfig(configFName)
+	cli.ExitOnError(os.Stderr, err, quiet)
+	if templateFNames == "" {
+		templateFNames = mkpage.ConfigString("mkpage", "templates")
+	}
+	mkpage.ConfigOverrides, err = mkpage.ParseConfigPairs(configPairs)
+	cli.ExitOnError(os.Stderr, err, quiet)
+
 	// Default template name is page.tmpl
 	templateName := "page.tmpl"
 	templateSources := []string{}
@@ -170,8 +365,6 @@ func main() {
 	}
 
 	// Setup IO
-	var err error
-
 	app.Eout = os.Stderr
 	app.In, err = cli.Open(inputFName, os.Stdin)
 	cli.ExitOnError(app.Eout, err, quiet)
@@ -197,7 +390,12 @@ func main() {
 		os.Exit(0)
 	}
 
-	data := map[string]string{}
+	// Render a whole content tree, e.g. mkpage -build CONTENT_DIR OUTPUT_DIR
+	if buildMode {
+		os.Exit(buildSite(app, args, templateSources))
+	}
+
+	data := mkpage.ConfigData("mkpage")
 	for i, arg := range args {
 		if strings.Contains(arg, "=") == true {
 			// Update data map
@@ -213,30 +411,80 @@ func main() {
 		}
 	}
 
-	// Create our Tmpl struct with our function map
-	tmpl := tmplfn.New(tmplfn.AllFuncs())
-
-	// Load any user supplied templates
+	// Read any template from the input that might be present
+	var templateSrc []byte
 	if len(templateSources) > 0 {
-		err = tmpl.ReadFiles(templateSources...)
-		cli.ExitOnError(app.Eout, err, quiet)
 		templateName = path.Base(templateSources[0])
 	} else if inputFName != "" {
-		// Read any templates from stdin that might be present
-		buf, err := ioutil.ReadAll(app.In)
-		cli.ExitOnError(app.Eout, err, quiet)
-		tmpl.Add(templateName, buf)
-	} else {
-		// Load our default template maps
-		err = tmpl.Add(templateName, mkpage.Defaults["/templates/page.tmpl"])
+		templateSrc, err = ioutil.ReadAll(app.In)
 		cli.ExitOnError(app.Eout, err, quiet)
 	}
 
-	// Build a template and send to MakePage
-	t, err := tmpl.Assemble()
-	cli.ExitOnError(app.Eout, err, quiet)
+	render := func() error {
+		// Create our Tmpl struct with our function map
+		tmpl := tmplfn.New(tmplfn.AllFuncs())
 
-	// Make the page
-	err = mkpage.MakePage(app.Out, templateName, t, data)
-	cli.ExitOnError(app.Eout, err, quiet)
+		// Load any user supplied templates
+		if len(templateSources) > 0 {
+			if err := tmpl.ReadFiles(templateSources...); err != nil {
+				return err
+			}
+		} else if inputFName != "" {
+			if watch && inputFName != "-" {
+				// Pickup edits to the template read from input
+				buf, err := ioutil.ReadFile(inputFName)
+				if err != nil {
+					return err
+				}
+				templateSrc = buf
+			}
+			tmpl.Add(templateName, templateSrc)
+		} else {
+			// Load our default template maps
+			if err := tmpl.Add(templateName, mkpage.Defaults["/templates/page.tmpl"]); err != nil {
+				return err
+			}
+		}
+
+		// Build a template and send to MakePage
+		t, err := tmpl.Assemble()
+		if err != nil {
+			return err
+		}
+
+		// Make the page, when watching the output file is rewritten
+		// each time.
+		if watch && outputFName != "" && outputFName != "-" {
+			fp, err := os.Create(outputFName)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+			return mkpage.MakePage(fp, templateName, t, data)
+		}
+		return mkpage.MakePage(app.Out, templateName, t, data)
+	}
+
+	if watch == false {
+		err = render()
+		cli.ExitOnError(app.Eout, err, quiet)
+		os.Exit(0)
+	}
+
+	// Render then re-render whenever the sources change
+	watcher := mkpage.NewWatcher(watchInterval, templateSources...)
+	watcher.Add(mkpage.DataFiles(data)...)
+	if len(templateSources) == 0 && inputFName != "-" {
+		watcher.Add(inputFName)
+	}
+	if err := render(); err != nil {
+		cli.OnError(app.Eout, err, quiet)
+	}
+	fmt.Fprintf(app.Eout, "Watching for changes, press ctrl-c to stop\n")
+	watcher.Watch(nil, func(changed []string) {
+		fmt.Fprintf(app.Eout, "Changed %s\n", strings.Join(changed, ", "))
+		if err := render(); err != nil {
+			cli.OnError(app.Eout, err, quiet)
+		}
+	})
 }
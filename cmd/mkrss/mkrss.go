@@ -19,12 +19,12 @@
 package main
 
 import (
-	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -32,7 +32,6 @@ import (
 	// My packages
 	"github.com/caltechlibrary/cli"
 	"github.com/caltechlibrary/mkpage"
-	"github.com/caltechlibrary/rss2"
 )
 
 var (
@@ -46,6 +45,80 @@ that the directory for HTDOCS is is the base directory containing
 subdirectories in the form of /YYYY/MM/DD/ARTICLE_HTML where 
 YYYY/MM/DD (Year, Month, Day) corresponds to the publication date 
 of ARTICLE_HTML.
+
+The feed is RSS 2.0 unless -format atom is given for an Atom 1.0
+feed or -format jsonfeed for a JSON Feed 1.1 document. Atom requires
+an author, -channel-author (or the channel title) is used for
+articles without a byline. When RSS_FILENAME is given the feed's
+rel="self" link (feed_url) is its URL under the channel link.
+
+Articles with draft = true in their front matter, a publishDate in
+the future or an expiryDate in the past are left out of the feed.
+Use -drafts and -future to include drafts and future articles
+(e.g. to preview a feed).
+
+An article's title, author, date, description and tags are taken
+from its front matter ("title", "creator" or "author", "date",
+"description" and "keywords" or "tags"). Articles without them fall
+back to the first line matching the title regexp (-t) and a byline
+(-b) with a date (-d), e.g. "By Jane Doe 2020-06-01". Articles whose
+date can't be found or parsed are reported and left out of the feed.
+
+Items get a description from the front matter "description", with
+-description articles without one are described by the text before
+a <!--more--> separator or else their first paragraph. With -content
+RSS items include the rendered article as content:encoded, Atom and
+JSON Feed items always include it. Tags become item categories
+(JSON Feed tags). Each item's guid (Atom and JSON Feed id) is its
+URL unless the front matter sets a "guid".
+
+PODCASTS
+
+An article's front matter can attach a media file, e.g. the audio
+of a talk, as its "enclosure". It is either a path or a table with
+"file" (a path or URL), "type" (the MIME type, guessed from the file
+extension if missing), "length" (only needed for URLs) and "duration"
+(e.g. "32:15"). Paths are relative to the article, or to HTDOCS if
+they start with "/", and the file's size is used as its length. An
+"image" (artwork) and "explicit" (true or false) can also be set.
+
+    ---
+    title: Our Latest Talk
+    date: 2020-06-01
+    image: talk.jpg
+    explicit: false
+    enclosure:
+      file: talk.mp3
+      type: audio/mpeg
+      duration: "32:15"
+    ---
+
+RSS items get an enclosure element and the iTunes podcast fields
+(itunes:duration, itunes:image and itunes:explicit). A feed with
+enclosures also gets the channel's itunes:author (-channel-author),
+itunes:category (-channel-category) and itunes:image
+(-channel-image). Atom entries get a rel="enclosure" link and JSON
+Feed items an attachment.
+
+FILTERING
+
+Items are sorted newest first, -c (-count) limits the number of items.
+Paths containing any part of the colon delimited -e (-exclude) list
+are excluded.
+With -tag (or -category) only articles whose front matter keywords
+or tags include one of the comma delimited tags are in the feed,
+e.g. a feed for each research group.
+
+CONFIGURATION
+
+Channel settings can be read from a site configuration file
+(mkpage.toml, mkpage.json or mkpage.yaml) in the current directory or
+named by MKPAGE_CONFIG or -config. Keys match the long option names
+(e.g. "channel-title", "channel-link", "channel-image", "format",
+"description", "content", "tag", "exclude", "count", "drafts") plus "docs" for HTDOCS
+and "rss" for RSS_FILENAME. Values in a [mkrss] section take
+precedence over top level values, e.g. a top level "url" is used as
+the channel link.
 `
 
 	examples = `
@@ -61,6 +134,27 @@ htdocs/myblog.
 
 This would build an RSS 2 file in htdocs/rss.xml from the
 articles in htdocs/myblog/YYYY/MM/DD.
+
+An Atom feed of the same articles is built with
+
+    %s -format atom -channel-title="This Great Beyond" \
+        -channel-author="Jane Doe" \
+        -channel-link="http://blog.example.org" \
+        htdocs htdocs/atom.xml
+
+A JSON Feed for a "latest posts" widget is built with
+
+    %s -format jsonfeed -channel-title="This Great Beyond" \
+        -channel-link="http://blog.example.org" \
+        htdocs htdocs/feed.json
+
+The ten newest articles tagged "biology", skipping drafts/ and
+archive/ directories, are published as their own feed with
+
+    %s -c 10 -tag biology -e drafts:archive \
+        -channel-title="Biology Group News" \
+        -channel-link="http://blog.example.org" \
+        htdocs htdocs/biology/rss.xml
 `
 
 	// Standard options
@@ -86,9 +180,18 @@ articles in htdocs/myblog/YYYY/MM/DD.
 	channelBuildDate   string
 	channelCopyright   string
 	channelCategory    string
+	channelAuthor      string
+	channelImage       string
+	format             string
 	bylineExp          string
 	titleExp           string
 	dateExp            string
+	configFName        string
+	includeDrafts      bool
+	includeFuture      bool
+	summarize          bool
+	includeContent     bool
+	tagList            string
 )
 
 func main() {
@@ -102,7 +205,10 @@ func main() {
 	// Add Help Docs
 	app.AddHelp("license", []byte(fmt.Sprintf(mkpage.LicenseText, appName, mkpage.Version)))
 	app.AddHelp("description", []byte(fmt.Sprintf(description, appName)))
-	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName)))
+	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName, appName, appName)))
+
+	// Environment options
+	app.EnvStringVar(&configFName, "MKPAGE_CONFIG", "", "set the site configuration file or directory")
 
 	// Standard options
 	app.BoolVar(&showHelp, "h,help", false, "display help")
@@ -116,8 +222,9 @@ func main() {
 	app.BoolVar(&generateManPage, "generate-manpage", false, "generate man page")
 
 	// App specific options
-	app.StringVar(&excludeList, "e", "", "A colon delimited list of path exclusions")
-	app.IntVar(&articleLimit, "c", 0, "If non-zero, limit the number of articles in the RSS file")
+	app.StringVar(&excludeList, "e,exclude", "", "A colon delimited list of path exclusions")
+	app.IntVar(&articleLimit, "c,count", 0, "If non-zero, limit the number of articles in the RSS file")
+	app.StringVar(&tagList, "category,tag", "", "A comma delimited list of front matter keywords or tags, only articles with one are included")
 	app.StringVar(&channelLanguage, "channel-language", "", "Language, e.g. en-ca")
 	app.StringVar(&channelTitle, "channel-title", "", "Title of channel")
 	app.StringVar(&channelDescription, "channel-description", "", "Description of channel")
@@ -127,9 +234,17 @@ func main() {
 	app.StringVar(&channelBuildDate, "channel-builddate", "", "Build Date for channel (e.g. 2006-01-02 15:04:05 -0700)")
 	app.StringVar(&channelCopyright, "channel-copyright", "", "Copyright for channel")
 	app.StringVar(&channelCategory, "channel-category", "", "category for channel")
+	app.StringVar(&channelAuthor, "channel-author", "", "author of channel, used by Atom feeds and podcasts")
+	app.StringVar(&channelImage, "channel-image", "", "URL (or path under HTDOCS) of the channel's artwork, used by podcasts")
+	app.StringVar(&format, "format", "", "feed format, rss (default), atom or jsonfeed")
+	app.BoolVar(&summarize, "description", false, "describe articles without a front matter description using the text before <!--more--> or their first paragraph")
+	app.BoolVar(&includeContent, "content", false, "include the rendered article in RSS items as content:encoded")
 	app.StringVar(&dateExp, "d,date-format", mkpage.DateExp, "set date regexp")
 	app.StringVar(&titleExp, "t,title", mkpage.TitleExp, "set title regexp")
 	app.StringVar(&bylineExp, "b,byline", mkpage.BylineExp, "set byline regexp")
+	app.StringVar(&configFName, "config", "", "read site configuration (mkpage.toml, mkpage.json or mkpage.yaml) from file or directory")
+	app.BoolVar(&includeDrafts, "drafts", false, "include articles marked draft = true")
+	app.BoolVar(&includeFuture, "future", false, "include articles with a publishDate in the future")
 
 	app.Parse()
 	args := app.Args()
@@ -171,6 +286,76 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Load site configuration, command line and environment take precedence
+	err = mkpage.LoadConfig(configFName)
+	cli.ExitOnError(app.Eout, err, quiet)
+	if len(excludeList) == 0 {
+		excludeList = mkpage.ConfigString("mkrss", "exclude")
+	}
+	if articleLimit == 0 {
+		articleLimit = mkpage.ConfigInt("mkrss", "count")
+	}
+	if len(channelLanguage) == 0 {
+		channelLanguage = mkpage.ConfigString("mkrss", "channel-language")
+	}
+	if len(channelTitle) == 0 {
+		channelTitle = mkpage.ConfigString("mkrss", "channel-title")
+	}
+	if len(channelDescription) == 0 {
+		channelDescription = mkpage.ConfigString("mkrss", "channel-description")
+	}
+	if len(channelLink) == 0 {
+		channelLink = mkpage.ConfigString("mkrss", "channel-link")
+	}
+	if len(channelLink) == 0 {
+		channelLink = mkpage.ConfigString("mkrss", "url")
+	}
+	if len(channelGenerator) == 0 {
+		channelGenerator = mkpage.ConfigString("mkrss", "channel-generator")
+	}
+	if len(channelCopyright) == 0 {
+		channelCopyright = mkpage.ConfigString("mkrss", "channel-copyright")
+	}
+	if len(channelCategory) == 0 {
+		channelCategory = mkpage.ConfigString("mkrss", "channel-category")
+	}
+	if len(channelAuthor) == 0 {
+		channelAuthor = mkpage.ConfigString("mkrss", "channel-author")
+	}
+	if len(channelImage) == 0 {
+		channelImage = mkpage.ConfigString("mkrss", "channel-image")
+	}
+	if len(format) == 0 {
+		format = mkpage.ConfigString("mkrss", "format")
+	}
+	if len(tagList) == 0 {
+		tagList = mkpage.ConfigString("mkrss", "tag")
+	}
+	if len(tagList) == 0 {
+		tagList = mkpage.ConfigString("mkrss", "category")
+	}
+	if summarize == false {
+		summarize = mkpage.ConfigBool("mkrss", "description")
+	}
+	if includeContent == false {
+		includeContent = mkpage.ConfigBool("mkrss", "content")
+	}
+	if includeDrafts == false {
+		includeDrafts = mkpage.ConfigBool("mkrss", "drafts")
+	}
+	if includeFuture == false {
+		includeFuture = mkpage.ConfigBool("mkrss", "future")
+	}
+
+	format = strings.ToLower(format)
+	switch format {
+	case "":
+		format = mkpage.FeedRSS
+	case mkpage.FeedRSS, mkpage.FeedAtom, mkpage.FeedJSON:
+	default:
+		cli.ExitOnError(app.Eout, fmt.Errorf("Unknown feed format %q, expected rss, atom or jsonfeed", format), quiet)
+	}
+
 	if len(channelTitle) == 0 {
 		channelTitle = `A website`
 	}
@@ -182,20 +367,14 @@ func main() {
 	}
 
 	// Setup the Channel metadata for feed.
-	feed := new(rss2.RSS2)
-	feed.Version = "2.0"
+	feed := new(mkpage.Feed)
 	feed.Title = channelTitle
 	feed.Description = channelDescription
 	feed.Link = channelLink
-	if len(channelLanguage) > 0 {
-		feed.Language = channelLanguage
-	}
-	if len(channelCopyright) > 0 {
-		feed.Copyright = channelCopyright
-	}
-	if len(channelCategory) > 0 {
-		feed.Category = channelCategory
-	}
+	feed.Language = channelLanguage
+	feed.Copyright = channelCopyright
+	feed.Category = channelCategory
+	feed.Author = channelAuthor
 	if len(channelGenerator) == 0 {
 		feed.Generator = app.Version()
 	} else {
@@ -203,41 +382,70 @@ func main() {
 	}
 	now := time.Now()
 	if len(channelPubDate) == 0 {
-		// RSS spec shows RTF 1123 dates
-		//feed.PubDate = now.Format(time.RFC822Z)
-		feed.PubDate = now.Format(time.RFC1123)
+		feed.PubDate = now
 	} else {
 		dt, err := mkpage.NormalizeDate(channelPubDate)
 		if err != nil {
 			cli.ExitOnError(app.Eout, fmt.Errorf("Can't parse %q, %s\n", channelPubDate, err), quiet)
 		}
-		feed.PubDate = dt.Format(time.RFC1123)
+		feed.PubDate = dt
 	}
 	if len(channelBuildDate) == 0 {
-		// RSS spec shows RTF 1123 dates
-		//feed.LastBuildDate = now.Format(time.RFC822Z)
-		feed.LastBuildDate = now.Format(time.RFC1123)
+		feed.Updated = now
 	} else {
 		dt, err := mkpage.NormalizeDate(channelBuildDate)
 		if err != nil {
 			cli.ExitOnError(app.Eout, fmt.Errorf("Can't parse %q, %s\n", channelBuildDate, err), quiet)
 		}
-		feed.LastBuildDate = dt.Format(time.RFC1123)
+		feed.Updated = dt
 	}
 
 	// Process command line parameters
-	htdocs := "."
-	rssPath := ""
+	htdocs := mkpage.ConfigString("mkrss", "docs")
+	if htdocs == "" {
+		htdocs = "."
+	}
+	rssPath := mkpage.ConfigString("mkrss", "rss")
 	if len(args) > 0 {
 		htdocs = args[0]
 	}
 	if len(args) > 1 {
 		rssPath = args[1]
 	}
+	if len(rssPath) > 0 {
+		// NOTE: Atom's rel="self" link is where the feed is published
+		rel, err := filepath.Rel(htdocs, rssPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = path.Base(rssPath)
+		}
+		feed.FeedURL = fmt.Sprintf("%s/%s", strings.TrimSuffix(channelLink, "/"), filepath.ToSlash(rel))
+	}
+	if len(channelImage) > 0 {
+		feed.Image = channelImage
+		if strings.HasPrefix(channelImage, "http://") == false && strings.HasPrefix(channelImage, "https://") == false {
+			feed.Image = fmt.Sprintf("%s/%s", strings.TrimSuffix(channelLink, "/"), strings.TrimPrefix(channelImage, "/"))
+		}
+	}
 
+	skipped := 0
+	excludes := []string{}
+	for _, s := range strings.Split(excludeList, ":") {
+		if s = strings.TrimSpace(s); s != "" {
+			excludes = append(excludes, s)
+		}
+	}
+	tags := []string{}
+	if len(tagList) > 0 {
+		tags = strings.Split(tagList, ",")
+	}
 	validBlogPath := regexp.MustCompile("/[0-9][0-9][0-9][0-9]/[0-9][0-9]/[0-9][0-9]/")
 	err = mkpage.Walk(htdocs, func(p string, info os.FileInfo) bool {
 		fname := path.Base(p)
+		for _, exclude := range excludes {
+			if strings.Contains(p, exclude) {
+				return false
+			}
+		}
 		if validBlogPath.MatchString(p) == true &&
 			strings.HasSuffix(fname, ".md") == true {
 			// NOTE: We have a possible published markdown article.
@@ -246,15 +454,17 @@ func main() {
 			if _, err := os.Stat(path.Join(p, path.Base(fname)+".html")); os.IsNotExist(err) {
 				return false
 			}
-			return true
+			// Skip drafts, articles not yet published and expired ones
+			frontMatter, err := mkpage.ReadFrontMatter(p)
+			if err != nil {
+				fmt.Fprintf(app.Eout, "Skipping %s, %s\n", p, err)
+				skipped++
+				return false
+			}
+			return mkpage.IsPublished(frontMatter, now, includeDrafts, includeFuture)
 		}
 		return false
 	}, func(p string, info os.FileInfo) error {
-		// Read the article
-		buf, err := ioutil.ReadFile(p)
-		if err != nil {
-			return err
-		}
 		// Calc URL path
 		pname := strings.TrimPrefix(p, htdocs)
 		if strings.HasPrefix(pname, "/") {
@@ -265,44 +475,79 @@ func main() {
 		articleURL := fmt.Sprintf("%s/%s", channelLink, path.Join(dname, bname))
 		u, err := url.Parse(articleURL)
 		if err != nil {
-			return err
-		}
-		// Collect metadata
-		src := fmt.Sprintf("%s", buf)
-		title := strings.TrimPrefix(mkpage.Grep(titleExp, src), "# ")
-		byline := mkpage.Grep(bylineExp, src)
-		pubDate := mkpage.Grep(dateExp, byline)
-		author := byline
-		if len(byline) > 2 {
-			author = strings.TrimSpace(strings.TrimSuffix(byline[2:], pubDate))
+			fmt.Fprintf(app.Eout, "Skipping %s, %s\n", p, err)
+			skipped++
+			return nil
 		}
-		// Reformat pubDate to conform to RSS2 date formats
-		dt, err := time.Parse(`2006-01-02`, pubDate)
+		// Collect metadata, front matter first then the byline
+		item, _, err := mkpage.ReadFeedItem(p, titleExp, bylineExp, dateExp)
 		if err != nil {
-			return err
+			fmt.Fprintf(app.Eout, "Skipping %s, %s\n", p, err)
+			skipped++
+			return nil
 		}
-		pubDate = dt.Format(time.RFC1123)
-		item := new(rss2.Item)
-		item.Title = title
-		item.Author = author
-		item.PubDate = pubDate
 		item.Link = u.String()
-		feed.ItemList = append(feed.ItemList, *item)
+		if err := item.ResolveMedia(p, htdocs, channelLink); err != nil {
+			fmt.Fprintf(app.Eout, "Skipping %s, %s\n", p, err)
+			skipped++
+			return nil
+		}
+		if len(tags) > 0 && item.HasTag(tags...) == false {
+			return nil
+		}
+		if format != mkpage.FeedRSS || summarize || includeContent {
+			data, err := mkpage.ResolveData(map[string]string{"content": p})
+			if err != nil {
+				fmt.Fprintf(app.Eout, "Skipping %s, %s\n", p, err)
+				skipped++
+				return nil
+			}
+			item.Content, _ = data["content"].(string)
+		}
+		if summarize && item.Summary == "" {
+			item.Summary = mkpage.Summarize(item.Content)
+		}
+		feed.Items = append(feed.Items, item)
 		return nil
 	})
 	if err != nil {
 		fmt.Fprintf(app.Eout, "%s\n", err)
 		os.Exit(1)
 	}
+	if skipped > 0 && quiet == false {
+		fmt.Fprintf(app.Eout, "%d article(s) skipped\n", skipped)
+	}
+	// Newest articles first, limited to -c articles
+	feed.SortItems()
+	if articleLimit > 0 && len(feed.Items) > articleLimit {
+		feed.Items = feed.Items[0:articleLimit]
+	}
 
-	// Marshal RSS2 and render output
-	src, err := xml.MarshalIndent(feed, "", "    ")
-	if err != nil {
-		fmt.Fprintf(app.Eout, "%s\n", err)
-		os.Exit(1)
+	// Marshal the feed and render output
+	var txt string
+	switch format {
+	case mkpage.FeedAtom:
+		src, err := feed.Atom()
+		if err != nil {
+			fmt.Fprintf(app.Eout, "%s\n", err)
+			os.Exit(1)
+		}
+		txt = string(src)
+	case mkpage.FeedJSON:
+		src, err := feed.JSONFeed()
+		if err != nil {
+			fmt.Fprintf(app.Eout, "%s\n", err)
+			os.Exit(1)
+		}
+		txt = string(src)
+	default:
+		src, err := feed.RSS(includeContent)
+		if err != nil {
+			fmt.Fprintf(app.Eout, "%s\n", err)
+			os.Exit(1)
+		}
+		txt = string(src)
 	}
-	txt := fmt.Sprintf(`<?xml version="1.0"?>
-%s`, src)
 	if len(rssPath) > 0 {
 		err = ioutil.WriteFile(rssPath, []byte(txt), 0664)
 		cli.ExitOnError(app.Eout, err, quiet)
@@ -25,6 +25,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/cli"
@@ -67,6 +68,21 @@ slides some predefined template variables is used.
 In your custom templates these should be exist to link everything together
 as expected.  In addition you may want to include JavaScript to allow mapping
 actions like "next slide" to the space bar or mourse click.
+
+CONFIGURATION
+
+Defaults for "templates" and a "data" table of key/value pairs can be
+set in a site configuration file (mkpage.toml, mkpage.json or mkpage.yaml)
+found in the current directory or named by MKPAGE_CONFIG or -config.
+Values in a [mkslides] section take precedence over top level values.
+Markup settings (e.g. a "gomarkdown" table) are merged with each slide's
+front matter and the -set option, see mkpage -help for details.
+
+WATCHING FOR CHANGES
+
+With -watch the slides are rendered then re-rendered whenever the
+Markdown file, templates or local files named in the key/value pairs
+change. Files are polled once a second, press ctrl-c to stop.
 `
 
 	examples = `
@@ -133,6 +149,9 @@ var (
 	presentationTitle string
 	showTemplate      bool
 	templateFNames    string
+	configFName       string
+	configPairs       string
+	watch             bool
 )
 
 func main() {
@@ -149,6 +168,7 @@ func main() {
 
 	// Environment options
 	app.EnvStringVar(&templateFNames, "MKPAGE_TEMPLATES", "", "a colon delimiter list of default templates to use")
+	app.EnvStringVar(&configFName, "MKPAGE_CONFIG", "", "set the site configuration file or directory")
 
 	// Standard options
 	app.BoolVar(&showHelp, "h", false, "display help")
@@ -174,6 +194,9 @@ func main() {
 	app.BoolVar(&showTemplate, "show-template", false, "display the default template")
 	app.StringVar(&templateFNames, "t", "", "A colon delimited list of HTML templates to use")
 	app.StringVar(&templateFNames, "templates", "", "A colon delimited list of HTML templates to use")
+	app.StringVar(&configFName, "config", "", "read site configuration (mkpage.toml, mkpage.json or mkpage.yaml) from file or directory")
+	app.StringVar(&configPairs, "set", "", "comma delimited configuration overrides, e.g. gomarkdown.Footnotes=true")
+	app.BoolVar(&watch, "watch", false, "re-render the slides when the Markdown, templates or data files change")
 
 	app.Parse()
 	args := app.Args()
@@ -209,6 +232,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Load site configuration, command line and environment take precedence
+	err := mkpage.LoadConfig(configFName)
+	cli.ExitOnError(os.Stderr, err, quiet)
+	if templateFNames == "" {
+		templateFNames = mkpage.ConfigString("mkslides", "templates")
+	}
+	mkpage.ConfigOverrides, err = mkpage.ParseConfigPairs(configPairs)
+	cli.ExitOnError(os.Stderr, err, quiet)
+
 	// Make sure we have a configured command to run
 	templateSources := []string{}
 	if len(templateFNames) > 0 {
@@ -217,7 +249,7 @@ func main() {
 		}
 	}
 
-	data := map[string]string{}
+	data := mkpage.ConfigData("mkslides")
 	for i, arg := range args {
 		switch {
 		case strings.Contains(arg, "=") == true:
@@ -236,55 +268,90 @@ func main() {
 		}
 	}
 
-	// Read in the Markdown file
-	mdSrc, err := ioutil.ReadFile(mdFName)
-	if err != nil {
-		cli.OnError(app.Eout, fmt.Errorf("%s, %s\n", mdFName, err), quiet)
-		os.Exit(1)
-	}
-
 	// Default Template Name is slides.tmpl
 	templateName := "slides.tmpl"
-
-	// Create our Tmpl with its function map
-	tmpl := tmplfn.New(tmplfn.AllFuncs())
-
-	// Load ant user supplied templates
 	if len(templateSources) > 0 {
-		err = tmpl.ReadFiles(templateSources...)
-		cli.ExitOnError(app.Eout, err, quiet)
 		templateName = templateSources[0]
-	} else {
-		// Read any templates from stdin that might be present
-		if cli.IsPipe(os.Stdin) == true {
-			buf, err := ioutil.ReadAll(os.Stdin)
-			cli.ExitOnError(app.Eout, err, quiet)
-			tmpl.Add(templateName, buf)
+	}
+
+	// Read any templates from stdin that might be present
+	var templateSrc []byte
+	if len(templateSources) == 0 && cli.IsPipe(os.Stdin) == true {
+		templateSrc, err = ioutil.ReadAll(os.Stdin)
+		cli.ExitOnError(app.Eout, err, quiet)
+	}
+
+	render := func() error {
+		// Read in the Markdown file
+		mdSrc, err := ioutil.ReadFile(mdFName)
+		if err != nil {
+			return fmt.Errorf("%s, %s", mdFName, err)
+		}
+
+		// Create our Tmpl with its function map
+		tmpl := tmplfn.New(tmplfn.AllFuncs())
+
+		// Load ant user supplied templates
+		if len(templateSources) > 0 {
+			if err := tmpl.ReadFiles(templateSources...); err != nil {
+				return err
+			}
+		} else if templateSrc != nil {
+			tmpl.Add(templateName, templateSrc)
 		} else {
 			// Load our default template maps
-			err = tmpl.Add(templateName, mkpage.Defaults["/templates/slides.tmpl"])
-			cli.ExitOnError(app.Eout, err, quiet)
+			if err := tmpl.Add(templateName, mkpage.Defaults["/templates/slides.tmpl"]); err != nil {
+				return err
+			}
+		}
+
+		// Assemble our templates
+		t, err := tmpl.Assemble()
+		if err != nil {
+			return err
+		}
+
+		// Build the slides
+		slides, err := mkpage.MarkdownToSlides(mdFName, mdSrc)
+		if err != nil {
+			return err
 		}
+
+		// Render the slides
+		for i, slide := range slides {
+			// Merge slide data with rest of command line map (e.g. "Title=text:My Presentation" "CSSPath=text:css/slides.css")
+			err = mkpage.MakeSlideFile(templateName, t, data, slide)
+			if err == nil {
+				// Note: Give some feed back when slide written successful
+				fmt.Fprintf(app.Eout, "Wrote %02d-%s.html\n", slide.CurNo, strings.TrimSuffix(path.Base(slide.FName), path.Ext(slide.FName)))
+			} else {
+				// Note: Display an error if we have a problem
+				cli.OnError(app.Eout, fmt.Errorf("Can't process %s slide %d, %s\n", mdFName, i, err), quiet)
+			}
+		}
+		return nil
 	}
 
-	// Assemble our templates
-	t, err := tmpl.Assemble()
-	cli.ExitOnError(app.Eout, err, quiet)
-
-	// Build the slides
-	slides, err := mkpage.MarkdownToSlides(mdFName, mdSrc)
-	cli.ExitOnError(app.Eout, err, quiet)
-
-	// Render the slides
-	for i, slide := range slides {
-		// Merge slide data with rest of command line map (e.g. "Title=text:My Presentation" "CSSPath=text:css/slides.css")
-		err = mkpage.MakeSlideFile(templateName, t, data, slide)
-		if err == nil {
-			// Note: Give some feed back when slide written successful
-			fmt.Fprintf(app.Eout, "Wrote %02d-%s.html\n", slide.CurNo, strings.TrimSuffix(path.Base(slide.FName), path.Ext(slide.FName)))
-		} else {
-			// Note: Display an error if we have a problem
-			cli.OnError(app.Eout, fmt.Errorf("Can't process slide %d, %s\n", i, err), quiet)
+	if watch == false {
+		if err := render(); err != nil {
+			cli.OnError(app.Eout, err, quiet)
+			os.Exit(1)
 		}
+		os.Exit(0)
+	}
+
+	// Render then re-render whenever the sources change
+	watcher := mkpage.NewWatcher(time.Second, mdFName)
+	watcher.Add(templateSources...)
+	watcher.Add(mkpage.DataFiles(data)...)
+	if err := render(); err != nil {
+		cli.OnError(app.Eout, err, quiet)
 	}
+	fmt.Fprintf(app.Eout, "Watching for changes, press ctrl-c to stop\n")
+	watcher.Watch(nil, func(changed []string) {
+		fmt.Fprintf(app.Eout, "Changed %s\n", strings.Join(changed, ", "))
+		if err := render(); err != nil {
+			cli.OnError(app.Eout, err, quiet)
+		}
+	})
 }
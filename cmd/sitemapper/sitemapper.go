@@ -20,35 +20,150 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	// Caltech Library packages
 	"github.com/caltechlibrary/cli"
 	"github.com/caltechlibrary/mkpage"
 )
 
-type locInfo struct {
-	Loc     string
-	LastMod string
-}
-
 var (
 	description = `
 SYNOPSIS
 
 %s generates a sitemap for the website.
 
+CONFIGURATION
+
+Settings can be read from a site configuration file (mkpage.toml,
+mkpage.json or mkpage.yaml) in the current directory or named by
+MKPAGE_CONFIG or -config. The keys "docs", "url", "sitemap", "update",
+"exclude", "section", "index", "language", "images", "robots",
+"robots_noindex", "drafts" and "future" match the long
+option names. Values in a [sitemapper] section take precedence over
+top level values. "section" may also be a table of PREFIX = FILE.
+
+LARGE SITES
+
+A sitemap file is limited to 50,000 URLs and 50MB. When the pages
+don't fit in one file they are written to numbered sitemaps next to
+MAP_FILENAME (e.g. sitemap-1.xml, sitemap-2.xml for sitemap.xml) and
+MAP_FILENAME becomes a sitemap index listing them, each with the
+lastmod of its most recently modified page. The index refers to the
+numbered sitemaps by their URL under PUBLIC_BASE_URL.
+
+SECTIONS
+
+Parts of a site (e.g. a blog or a sub-site) can have their own
+sitemap. The -section option takes PREFIX=FILE, it can be repeated
+or given a comma delimited list of them. PREFIX is the start of a page's path under
+HTDOCS_PATH and FILE is the section's sitemap relative to
+HTDOCS_PATH. Pages are put in the section with the longest matching
+prefix, the rest go to MAP_FILENAME. A sitemap index referencing
+all the sitemaps is written to -index (defaults to MAP_FILENAME with
+"-index" added, e.g. sitemap-index.xml). Sections without pages are
+skipped, the directories of the others are created if needed.
+
+In TOML the sections can be given as a table
+
+    [sitemapper.section]
+    "/blog/" = "blog/sitemap.xml"
+    "/news/" = "news/sitemap.xml"
+
+TRANSLATIONS
+
+Translations of a page are linked to each other with xhtml:link
+elements giving each version's hreflang. A translation is named
+after the page with its language before the extension, e.g.
+page.es.html is the Spanish version of page.html. Pages can also
+be grouped by "translationKey" and their language set by "language"
+in their document's front matter. The -language option sets the
+language of pages not otherwise given one, without it they are
+listed as the "x-default" version.
+
+IMAGES AND VIDEOS
+
+With -images each page is read for img elements and video elements
+with a poster. They are listed in the page's url element as
+image:image and video:video elements with their locations resolved
+against the page's URL. A video's title comes from its title
+attribute or the page's title, its description from the page's
+meta description. Videos without a poster or a src (on the video or
+its first source element) are left out.
+
+ROBOTS.TXT
+
+With -robots a robots.txt is written to HTDOCS_PATH with a Sitemap
+line for each sitemap and sitemap index written. Its rules come from
+"robots_rules" in the site configuration, a list of tables with a
+"user_agent", "allow" and "disallow" paths and a "crawl_delay". With
+-robots-noindex pages whose document has noindex = true are also
+disallowed, for all user agents and in each named user agent's
+rules. For example in TOML
+
+    [sitemapper]
+    robots = true
+    [[sitemapper.robots_rules]]
+    user_agent = "*"
+    disallow = [ "/private/", "/tmp/" ]
+    [[sitemapper.robots_rules]]
+    user_agent = [ "BadBot" ]
+    disallow = "/"
+    crawl_delay = 10
+
+Without rules all user agents are allowed everything.
+
+FRONT MATTER
+
+When an HTML page has the document it was rendered from along side
+it (e.g. index.md for index.html) the document's front matter decides
+how the page is listed.
+
++ draft = true, a publishDate in the future or an expiryDate in the
+  past leave the page out (use -drafts and -future to include drafts
+  and future pages)
++ noindex = true leaves the page out
++ lastmod replaces the page's modification time
++ a "sitemap" table can set "changefreq" (e.g. weekly, replacing
+  -update), "priority" (0.0 to 1.0) and "exclude" (true leaves the
+  page out)
+
+For example in TOML
+
+    lastmod = 2020-06-01
+    [sitemap]
+    changefreq = "monthly"
+    priority = 0.8
+
+Pages without a document use their modification time and -update,
+error pages (e.g. 404.html and 500.html) are left out.
+
 `
 
 	examples = `
 EXAMPLE
 
     %s htdocs htdocs/sitemap.xml http://eprints.example.edu
+
+Give the blog its own sitemap, htdocs/blog/sitemap.xml, with
+htdocs/sitemap-index.xml referencing it and htdocs/sitemap.xml
+
+    %s -section /blog/=blog/sitemap.xml \
+        htdocs htdocs/sitemap.xml http://eprints.example.edu
+
+Give the blog and news their own sitemaps
+
+    %s -section /blog/=blog/sitemap.xml \
+        -section /news/=news/sitemap.xml \
+        htdocs htdocs/sitemap.xml http://eprints.example.edu
 `
 
 	// Standard options
@@ -62,13 +177,21 @@ EXAMPLE
 	generateManPage  bool
 
 	// App options
-	htdocs       string
-	siteURL      string
-	excludeList  string
-	sitemapFName string
-
-	changefreq string
-	locList    []*locInfo
+	htdocs        string
+	siteURL       string
+	excludeList   string
+	sitemapFName  string
+	sectionList   string
+	indexFName    string
+	language      string
+	listImages    bool
+	writeRobots   bool
+	robotsNoindex bool
+
+	changefreq    string
+	configFName   string
+	includeDrafts bool
+	includeFuture bool
 )
 
 // ExcludeList is a list of directories to skip when generating a sitemap
@@ -93,6 +216,81 @@ func (dirList ExcludeList) Exclude(p string) bool {
 	return false
 }
 
+// sourceFrontMatter returns the front matter of the document an HTML
+// page was rendered from (e.g. index.md for index.html) and true if
+// the page has a document.
+func sourceFrontMatter(p string) (map[string]interface{}, bool, error) {
+	base := strings.TrimSuffix(p, path.Ext(p))
+	for _, ext := range []string{".md", ".mmark", ".fountain", ".spmd"} {
+		if _, err := os.Stat(base + ext); err != nil {
+			continue
+		}
+		frontMatter, err := mkpage.ReadFrontMatter(base + ext)
+		return frontMatter, true, err
+	}
+	return nil, false, nil
+}
+
+// Section holds the pages of a site whose path starts with Prefix
+type Section struct {
+	Prefix string
+	*mkpage.SitemapSection
+}
+
+// parseSections returns the sections of a comma delimited list of
+// PREFIX=FILE, FILE is relative to htdocs.
+func parseSections(htdocs string, s string) ([]*Section, error) {
+	sections := []*Section{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("Can't parse section %q, expected PREFIX=FILE", pair)
+		}
+		sections = append(sections, &Section{
+			Prefix: "/" + strings.TrimPrefix(strings.TrimSpace(parts[0]), "/"),
+			SitemapSection: &mkpage.SitemapSection{
+				FName: filepath.Join(htdocs, strings.TrimSpace(parts[1])),
+			},
+		})
+	}
+	// NOTE: the longest prefix is checked first
+	sort.SliceStable(sections, func(i, j int) bool {
+		return len(sections[i].Prefix) > len(sections[j].Prefix)
+	})
+	return sections, nil
+}
+
+// configSections returns the "section" setting as a comma delimited
+// list of PREFIX=FILE, it may be a string or a table.
+func configSections() string {
+	val, ok := mkpage.ConfigValue("sitemapper", "section")
+	if ok == false {
+		return ""
+	}
+	if m, ok := val.(map[string]interface{}); ok == true {
+		pairs := []string{}
+		for prefix, fName := range m {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", prefix, fName))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return mkpage.ConfigString("sitemapper", "section")
+}
+
+// dirURL returns the URL of the directory holding fName
+func dirURL(site *url.URL, htdocs string, fName string) string {
+	return mkpage.SitemapDirURL(site, htdocs, fName)
+}
+
+// fileURL returns the URL of fName
+func fileURL(site *url.URL, htdocs string, fName string) string {
+	return strings.TrimSuffix(dirURL(site, htdocs, fName), "/") + "/" + filepath.Base(fName)
+}
+
 func main() {
 	app := cli.NewCli(mkpage.Version)
 	appName := app.AppName()
@@ -103,12 +301,13 @@ func main() {
 	// Add Help Docs
 	app.AddHelp("license", []byte(fmt.Sprintf(mkpage.LicenseText, appName, mkpage.Version)))
 	app.AddHelp("description", []byte(fmt.Sprintf(description, appName)))
-	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName)))
+	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName, appName)))
 
 	// Setup environment options
 	app.EnvStringVar(&htdocs, "MKPAGE_DOCROOT", "", "set the document root, defaults to current working directory")
 	app.EnvStringVar(&siteURL, "MKPAGE_SITEURL", "", "set the site url")
 	app.EnvStringVar(&sitemapFName, "MKPAGE_SITEMAP", "", "set the sitemap filename and path")
+	app.EnvStringVar(&configFName, "MKPAGE_CONFIG", "", "set the site configuration file or directory")
 
 	// Setup options
 	app.BoolVar(&showHelp, "h,help", false, "display help")
@@ -124,8 +323,17 @@ func main() {
 	app.StringVar(&htdocs, "docs", "", "set the htdoc root")
 	app.StringVar(&siteURL, "url", "", "set the site URL")
 	app.StringVar(&sitemapFName, "sitemap", "", "set the sitemap filename and path")
-	app.StringVar(&changefreq, "update,update-frequency", "daily", "Set the change frequencely value, e.g. daily, weekly, monthly")
+	app.StringVar(&changefreq, "update,update-frequency", "", "Set the change frequencely value, e.g. daily, weekly, monthly")
 	app.StringVar(&excludeList, "exclude", "", "A colon delimited list of path parts to exclude from sitemap")
+	app.StringVar(&configFName, "config", "", "read site configuration (mkpage.toml, mkpage.json or mkpage.yaml) from file or directory")
+	app.StringVar(&sectionList, "section", "", "a comma delimited list of PREFIX=FILE giving pages under PREFIX their own sitemap")
+	app.StringVar(&indexFName, "index", "", "set the sitemap index filename and path used with -section")
+	app.StringVar(&language, "language", "", "set the language of pages without one, e.g. en")
+	app.BoolVar(&listImages, "images", false, "list the images and videos of each page")
+	app.BoolVar(&writeRobots, "robots", false, "write robots.txt to the document root")
+	app.BoolVar(&robotsNoindex, "robots-noindex", false, "disallow pages marked noindex = true in robots.txt")
+	app.BoolVar(&includeDrafts, "drafts", false, "include pages whose document is marked draft = true")
+	app.BoolVar(&includeFuture, "future", false, "include pages whose document has a publishDate in the future")
 
 	// Setup IO
 	var err error
@@ -141,6 +349,11 @@ func main() {
 	app.Parse()
 	args := app.Args()
 
+	// NOTE: -section can be repeated, collect every value
+	if values := mkpage.FlagValues(os.Args[1:], "section"); len(values) > 1 {
+		sectionList = strings.Join(values, ",")
+	}
+
 	if generateMarkdown {
 		app.GenerateMarkdown(app.Out)
 		os.Exit(0)
@@ -166,8 +379,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	if len(args) != 3 {
-		cli.ExitOnError(app.Eout, fmt.Errorf("%s requires 3 parameters, see %s --help\n", appName, appName), quiet)
+	if len(args) > 3 {
+		cli.ExitOnError(app.Eout, fmt.Errorf("%s accepts at most 3 parameters, see %s --help\n", appName, appName), quiet)
 	}
 
 	if len(args) > 0 {
@@ -180,6 +393,49 @@ func main() {
 		siteURL = args[2]
 	}
 
+	// Load site configuration, command line and environment take precedence
+	err = mkpage.LoadConfig(configFName)
+	cli.ExitOnError(app.Eout, err, quiet)
+	if htdocs == "" {
+		htdocs = mkpage.ConfigString("sitemapper", "docs")
+	}
+	if siteURL == "" {
+		siteURL = mkpage.ConfigString("sitemapper", "url")
+	}
+	if sitemapFName == "" {
+		sitemapFName = mkpage.ConfigString("sitemapper", "sitemap")
+	}
+	if changefreq == "" {
+		changefreq = mkpage.ConfigString("sitemapper", "update")
+	}
+	if excludeList == "" {
+		excludeList = mkpage.ConfigString("sitemapper", "exclude")
+	}
+	if sectionList == "" {
+		sectionList = configSections()
+	}
+	if indexFName == "" {
+		indexFName = mkpage.ConfigString("sitemapper", "index")
+	}
+	if language == "" {
+		language = mkpage.ConfigString("sitemapper", "language")
+	}
+	if listImages == false {
+		listImages = mkpage.ConfigBool("sitemapper", "images")
+	}
+	if writeRobots == false {
+		writeRobots = mkpage.ConfigBool("sitemapper", "robots")
+	}
+	if robotsNoindex == false {
+		robotsNoindex = mkpage.ConfigBool("sitemapper", "robots_noindex")
+	}
+	if includeDrafts == false {
+		includeDrafts = mkpage.ConfigBool("sitemapper", "drafts")
+	}
+	if includeFuture == false {
+		includeFuture = mkpage.ConfigBool("sitemapper", "future")
+	}
+
 	// Required
 	if htdocs == "" {
 		cli.ExitOnError(app.Eout, fmt.Errorf("Missing document root, set with MKPAGE_DOCROOT or -docs option"), quiet)
@@ -203,44 +459,142 @@ func main() {
 
 	excludeDirs := ExcludeList(strings.Split(excludeList, ":"))
 
+	sections, err := parseSections(htdocs, sectionList)
+	cli.ExitOnError(app.Eout, err, quiet)
+	if indexFName == "" {
+		ext := filepath.Ext(sitemapFName)
+		indexFName = strings.TrimSuffix(sitemapFName, ext) + "-index" + ext
+	}
+
+	robots := new(mkpage.Robots)
+	if writeRobots {
+		rules, _ := mkpage.ConfigValue("sitemapper", "robots_rules")
+		robots.Groups, err = mkpage.RobotsGroups(rules)
+		cli.ExitOnError(app.Eout, err, quiet)
+	}
+
+	now := time.Now()
+	sitemap := new(mkpage.Sitemap)
+	pages := []*mkpage.SitemapURL{}
 	log.Printf("Starting map of %s\n", htdocs)
 	filepath.Walk(htdocs, func(p string, info os.FileInfo, err error) error {
-		if strings.HasSuffix(p, ".html") {
+		if err != nil {
+			// NOTE: info is nil when an entry can't be read
+			log.Printf("Skipping %q, %s", p, err)
+			return nil
+		}
+		if strings.HasSuffix(p, ".html") == false || excludeDirs.Exclude(p) {
+			return nil
+		}
+		//FIXME: should use the parsed URL and append to path
+		page, _ := url.Parse(site.String())
+		page.Path = path.Join(page.Path, strings.TrimPrefix(p, htdocs))
+		pagePath := "/" + strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(p, htdocs)), "/")
+		translationKey, pageLanguage := mkpage.SplitLanguage(pagePath)
+		loc := &mkpage.SitemapURL{
+			Loc:            page.String(),
+			LastMod:        info.ModTime(),
+			ChangeFreq:     changefreq,
+			Language:       pageLanguage,
+			TranslationKey: translationKey,
+		}
+		frontMatter, hasDocument, err := sourceFrontMatter(p)
+		switch {
+		case err != nil:
+			log.Printf("Skipping %q, %s", p, err)
+			return nil
+		case hasDocument:
+			// NOTE: the document's front matter decides if the page is listed
+			if mkpage.IsPublished(frontMatter, now, includeDrafts, includeFuture) == false {
+				log.Printf("Skipping %q, not published", p)
+				return nil
+			}
+			if robotsNoindex && mkpage.NoIndex(frontMatter) {
+				robots.Disallow(pagePath)
+			}
+			if loc.ApplyFrontMatter(frontMatter) == false {
+				log.Printf("Skipping %q, excluded by front matter", p)
+				return nil
+			}
+		default:
+			//NOTE: You can skip the error pages (e.g. 404.html, 500.html) in the sitemap
 			fname := path.Base(p)
-			//NOTE: You can skip the eror pages, and excluded directories in the sitemap
-			if strings.HasPrefix(fname, "50") == false && strings.HasPrefix(p, "40") == false && excludeDirs.Exclude(p) == false {
-				finfo := new(locInfo)
-				//FIXME: should use the parsed URL and append to path
-				page, _ := url.Parse(site.String())
-				page.Path = path.Join(page.Path, strings.TrimPrefix(p, htdocs))
-				finfo.Loc = page.String()
-				yr, mn, dy := info.ModTime().Date()
-				finfo.LastMod = fmt.Sprintf("%d-%0.2d-%0.2d", yr, mn, dy)
-				log.Printf("Adding %s\n", finfo.Loc)
-				locList = append(locList, finfo)
+			if strings.HasPrefix(fname, "50") || strings.HasPrefix(fname, "40") {
+				log.Printf("Skipping %q", p)
+				return nil
+			}
+		}
+		if loc.Language == "" {
+			loc.Language = language
+		}
+		if listImages {
+			src, err := ioutil.ReadFile(p)
+			if err == nil {
+				loc.Images, loc.Videos, err = mkpage.ReadSitemapMedia(src, loc.Loc)
+			}
+			if err != nil {
+				log.Printf("Can't read images of %q, %s", p, err)
+			}
+		}
+		pages = append(pages, loc)
+		for _, section := range sections {
+			if strings.HasPrefix(pagePath, section.Prefix) {
+				log.Printf("Adding %s to %s\n", loc.Loc, section.FName)
+				section.URLs = append(section.URLs, loc)
+				return nil
 			}
 		}
+		log.Printf("Adding %s\n", loc.Loc)
+		sitemap.URLs = append(sitemap.URLs, loc)
 		return nil
 	})
-	fmt.Printf("Writing %s\n", sitemapFName)
-	fp, err := os.OpenFile(sitemapFName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
-	if err != nil {
-		log.Fatalf("Can't create %s, %s\n", sitemapFName, err)
-	}
-	defer fp.Close()
-	fp.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
-<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
-`))
-	for _, item := range locList {
-		fp.WriteString(fmt.Sprintf(`
-    <url>
-            <loc>%s</loc>
-            <lastmod>%s</lastmod>
-            <changefreq>%s</changefreq>
-    </url>
-`, item.Loc, item.LastMod, changefreq))
-	}
-	fp.Write([]byte(`
-</urlset>
-`))
+	mkpage.LinkTranslations(pages)
+	// sitemaps are the URLs of the sitemaps and indexes for robots.txt
+	sitemaps := []string{fileURL(site, htdocs, sitemapFName)}
+	if len(sections) > 0 {
+		sectionMaps := []*mkpage.SitemapSection{
+			{
+				Sitemap: *sitemap,
+				FName:   sitemapFName,
+				BaseURL: dirURL(site, htdocs, sitemapFName),
+			},
+		}
+		for _, section := range sections {
+			section.BaseURL = dirURL(site, htdocs, section.FName)
+			sectionMaps = append(sectionMaps, section.SitemapSection)
+		}
+		written, err := mkpage.WriteSitemapSections(indexFName, sectionMaps)
+		if err != nil {
+			log.Fatalf("%s\n", err)
+		}
+		// NOTE: only list the sitemaps written, empty sections are skipped
+		sitemaps = []string{}
+		for _, section := range sectionMaps {
+			for _, fName := range written {
+				if fName == section.FName {
+					fmt.Printf("Wrote %s\n", section.FName)
+					sitemaps = append(sitemaps, fileURL(site, htdocs, section.FName))
+				}
+			}
+		}
+		log.Printf("Wrote sitemap index %s\n", indexFName)
+		sitemaps = append(sitemaps, fileURL(site, htdocs, indexFName))
+	} else {
+		fmt.Printf("Writing %s\n", sitemapFName)
+		written, err := sitemap.Write(sitemapFName, dirURL(site, htdocs, sitemapFName))
+		if err != nil {
+			log.Fatalf("Can't create %s, %s\n", sitemapFName, err)
+		}
+		if len(written) > 1 {
+			log.Printf("Wrote %d sitemaps and an index of them to %s\n", len(written)-1, sitemapFName)
+		}
+	}
+	if writeRobots {
+		robots.Sitemaps = sitemaps
+		robotsFName := filepath.Join(htdocs, "robots.txt")
+		fmt.Printf("Writing %s\n", robotsFName)
+		if err := ioutil.WriteFile(robotsFName, []byte(robots.String()), 0664); err != nil {
+			log.Fatalf("Can't create %s, %s\n", robotsFName, err)
+		}
+	}
 }
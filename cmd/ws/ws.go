@@ -59,6 +59,34 @@ supported.
 + MKPAGE_DOCROOT - sets the document path to use
 + MKPAGE_SSL_KEY - the path to the SSL key if using https
 + MKPAGE_SSL_CERT - the path to the SSL cert if using https
++ MKPAGE_CONFIG - the site configuration file or directory
+
+%s also reads a site configuration file (mkpage.toml, mkpage.json or
+mkpage.yaml) from the current directory or MKPAGE_CONFIG. The keys
+"docs", "listen", "key", "cert", "cors-origin", "redirects-csv",
+"acme", "live-reload", "render", "templates" and a "data" table are
+read from a [ws] section or the top level. Command line options and
+environment variables take precedence.
+
+LIVE RELOAD
+
+With -live-reload the DOCROOT is polled for changes once a second.
+A small script is added to each HTML page served, it listens for
+Server-Sent Events on /_live-reload and reloads the page when a
+file changes. Running "mkpage -watch -build" in another terminal
+gives a preview that follows your edits.
+
+RENDERING DOCUMENTS
+
+With -render a request for a page that doesn't exist, e.g. /about.html
+or /blog/ (i.e. /blog/index.html), is answered by rendering about.md,
+about.mmark or about.fountain the same way "mkpage -build" does. The
+document's front matter can pick the template and markup processor,
+otherwise a page.tmpl in the document's directory (or a parent),
+then -templates (or MKPAGE_TEMPLATES), then the default page template
+is used. Rendered pages are cached until the document, its templates
+or data files are modified. This lets you preview drafts without
+a build step.
 
 `
 
@@ -75,6 +103,10 @@ Run web server using a specified directory
 
    %s /www/htdocs
 
+Run web server reloading pages in the browser as htdocs changes
+
+   %s -live-reload htdocs
+
 Running web server using ACME TLS support (i.e. Let's Encrypt).
 Note will only include the hostname as the ACME setup is for
 listenning on port 443. This may require privileged account
@@ -97,13 +129,17 @@ issue the cert, see https://letsencrypt.org for details)
 	quiet            bool
 
 	// local app options
-	uri          string
-	docRoot      string
-	sslKey       string
-	sslCert      string
-	letsEncrypt  bool
-	CORSOrigin   string
-	redirectsCSV string
+	uri            string
+	docRoot        string
+	sslKey         string
+	sslCert        string
+	letsEncrypt    bool
+	CORSOrigin     string
+	redirectsCSV   string
+	configFName    string
+	liveReload     bool
+	render         bool
+	templateFNames string
 )
 
 func logRequest(r *http.Request) {
@@ -126,8 +162,8 @@ func main() {
 
 	// Add Help Docs
 	app.AddHelp("license", []byte(fmt.Sprintf(mkpage.LicenseText, appName, mkpage.Version)))
-	app.AddHelp("description", []byte(fmt.Sprintf(description, appName, appName)))
-	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName, appName)))
+	app.AddHelp("description", []byte(fmt.Sprintf(description, appName, appName, appName)))
+	app.AddHelp("examples", []byte(fmt.Sprintf(examples, appName, appName, appName, appName)))
 
 	defaultDocRoot := "."
 	defaultURL := "http://localhost:8000"
@@ -137,6 +173,8 @@ func main() {
 	app.EnvStringVar(&uri, "MKPAGE_URL", "", "set the URL to listen on, defaults to http://localhost:8000")
 	app.EnvStringVar(&sslKey, "MKPAGE_SSL_KEY", "", "set the path to the SSL KEY")
 	app.EnvStringVar(&sslCert, "MKPAGE_SSL_CERT", "", "set the path to the SSL Certificate")
+	app.EnvStringVar(&configFName, "MKPAGE_CONFIG", "", "set the site configuration file or directory")
+	app.EnvStringVar(&templateFNames, "MKPAGE_TEMPLATES", "", "set the default templates used with -render")
 
 	// Standard Options
 	app.BoolVar(&showHelp, "h", false, "display help")
@@ -151,17 +189,21 @@ func main() {
 	app.BoolVar(&quiet, "quiet", false, "suppress error messages")
 
 	// Application Options
-	app.StringVar(&docRoot, "d", defaultDocRoot, "Set the htdocs path")
-	app.StringVar(&docRoot, "docs", defaultDocRoot, "Set the htdocs path")
-	app.StringVar(&uri, "u", defaultURL, "The protocol and hostname listen for as a URL")
-	app.StringVar(&uri, "url", defaultURL, "The protocol and hostname listen for as a URL")
+	app.StringVar(&docRoot, "d", "", "Set the htdocs path")
+	app.StringVar(&docRoot, "docs", "", "Set the htdocs path")
+	app.StringVar(&uri, "u", "", "The protocol and hostname listen for as a URL")
+	app.StringVar(&uri, "url", "", "The protocol and hostname listen for as a URL")
 	app.StringVar(&sslKey, "k", "", "Set the path for the SSL Key")
 	app.StringVar(&sslKey, "key", "", "Set the path for the SSL Key")
 	app.StringVar(&sslCert, "c", "", "Set the path for the SSL Cert")
 	app.StringVar(&sslCert, "cert", "", "Set the path for the SSL Cert")
 	app.BoolVar(&letsEncrypt, "acme", false, "Enable Let's Encypt ACME TLS support")
-	app.StringVar(&CORSOrigin, "cors-origin", "*", "Set the CORS Origin Policy to a specific host or *")
+	app.StringVar(&CORSOrigin, "cors-origin", "", "Set the CORS Origin Policy to a specific host or *")
 	app.StringVar(&redirectsCSV, "redirects-csv", "", "Use target,destination replacement paths defined in CSV file")
+	app.StringVar(&configFName, "config", "", "read site configuration (mkpage.toml, mkpage.json or mkpage.yaml) from file or directory")
+	app.BoolVar(&liveReload, "live-reload", false, "reload pages open in the browser when files in the htdocs path change")
+	app.BoolVar(&render, "render", false, "render .md, .mmark and .fountain documents when the requested .html file doesn't exist")
+	app.StringVar(&templateFNames, "t,templates", "", "colon delimited list of templates used with -render")
 
 	app.Parse()
 	args := app.Args()
@@ -206,6 +248,49 @@ func main() {
 		docRoot = args[0]
 	}
 
+	// Load site configuration, command line and environment take precedence
+	err = mkpage.LoadConfig(configFName)
+	cli.ExitOnError(app.Eout, err, quiet)
+	if docRoot == "" {
+		docRoot = mkpage.ConfigString("ws", "docs")
+	}
+	if uri == "" {
+		uri = mkpage.ConfigString("ws", "listen")
+	}
+	if sslKey == "" {
+		sslKey = mkpage.ConfigString("ws", "key")
+	}
+	if sslCert == "" {
+		sslCert = mkpage.ConfigString("ws", "cert")
+	}
+	if CORSOrigin == "" {
+		CORSOrigin = mkpage.ConfigString("ws", "cors-origin")
+	}
+	if redirectsCSV == "" {
+		redirectsCSV = mkpage.ConfigString("ws", "redirects-csv")
+	}
+	if mkpage.ConfigBool("ws", "acme") {
+		letsEncrypt = true
+	}
+	if mkpage.ConfigBool("ws", "live-reload") {
+		liveReload = true
+	}
+	if mkpage.ConfigBool("ws", "render") {
+		render = true
+	}
+	if templateFNames == "" {
+		templateFNames = mkpage.ConfigString("ws", "templates")
+	}
+	if docRoot == "" {
+		docRoot = defaultDocRoot
+	}
+	if uri == "" {
+		uri = defaultURL
+	}
+	if CORSOrigin == "" {
+		CORSOrigin = "*"
+	}
+
 	log.Printf("DocRoot %s", docRoot)
 
 	u, err := url.Parse(uri)
@@ -263,7 +348,26 @@ func main() {
 			log.Fatalf("Can't make redirect service, %s", err)
 		}
 	}
-	http.Handle("/", cors.Handler(http.FileServer(http.Dir(docRoot))))
+	handler := http.FileServer(http.Dir(docRoot))
+	if render {
+		// Render documents for requested pages that don't exist
+		site := new(mkpage.Site)
+		site.ContentDir = docRoot
+		site.Data = mkpage.ConfigData("ws")
+		if templateFNames != "" {
+			site.Templates = strings.Split(templateFNames, ":")
+		}
+		log.Printf("Rendering documents on request")
+		handler = site.RenderHandler(handler)
+	}
+	if liveReload {
+		// Reload pages in the browser when DocRoot changes
+		lr := mkpage.NewLiveReload()
+		go lr.Watch(docRoot, nil)
+		log.Printf("Live reload enabled, watching %s", docRoot)
+		handler = lr.Handler(handler)
+	}
+	http.Handle("/", cors.Handler(handler))
 
 	if u.Scheme == "https" {
 		if rService != nil {
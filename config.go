@@ -0,0 +1,341 @@
+//
+// Package mkpage config.go provides the site configuration loader
+// used by mkpage, mkslides, mkrss, sitemapper and ws.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ConfigNames holds the filenames, in order of preference,
+	// searched for when looking for a site configuration.
+	ConfigNames = []string{
+		"mkpage.toml",
+		"mkpage.json",
+		"mkpage.yaml",
+	}
+)
+
+// FindConfig looks in dName for mkpage.toml, mkpage.json or
+// mkpage.yaml and returns the path of the first one found. An
+// empty string is returned if none are present.
+func FindConfig(dName string) string {
+	for _, name := range ConfigNames {
+		fName := path.Join(dName, name)
+		if info, err := os.Stat(fName); err == nil && info.IsDir() == false {
+			return fName
+		}
+	}
+	return ""
+}
+
+// ReadConfig reads a TOML, JSON or YAML file (based on file extension)
+// and returns a map[string]interface{} the same way front matter
+// is returned by ProcessorConfig.
+func ReadConfig(fName string) (map[string]interface{}, error) {
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	configType := ConfigIsUnknown
+	switch strings.ToLower(path.Ext(fName)) {
+	case ".toml":
+		configType = ConfigIsTOML
+	case ".json":
+		configType = ConfigIsJSON
+	case ".yaml", ".yml":
+		configType = ConfigIsYAML
+	}
+	m, err := ProcessorConfig(configType, normalizeEOL(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	return m, nil
+}
+
+// LoadConfig populates Config from a site configuration. If name is
+// a file it is read directly, if name is a directory it is searched
+// for one of ConfigNames, if name is empty the current working directory
+// is searched. It is not an error for a directory to lack a
+// site configuration, Config is then left empty.
+func LoadConfig(name string) error {
+	fName := ""
+	if name == "" {
+		name = "."
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		fName = FindConfig(name)
+	} else {
+		fName = name
+	}
+	Config = map[string]interface{}{}
+	if fName == "" {
+		return nil
+	}
+	m, err := ReadConfig(fName)
+	if err != nil {
+		return err
+	}
+	Config = m
+	return nil
+}
+
+// ConfigValue looks up key in the section (e.g. "mkrss") of Config
+// then falls back to the top level of Config. The boolean is false
+// if the key wasn't found.
+func ConfigValue(section, key string) (interface{}, bool) {
+	if Config == nil {
+		return nil, false
+	}
+	if thing, ok := Config[section]; ok == true {
+		if m, ok := thing.(map[string]interface{}); ok == true {
+			if val, ok := m[key]; ok == true {
+				return val, true
+			}
+		}
+	}
+	val, ok := Config[key]
+	return val, ok
+}
+
+// ConfigString returns key from section (or top level) of Config
+// as a string. An empty string is returned if key isn't found.
+func ConfigString(section, key string) string {
+	val, ok := ConfigValue(section, key)
+	if ok == false || val == nil {
+		return ""
+	}
+	switch v := val.(type) {
+	case string:
+		return v
+	case []interface{}:
+		// NOTE: arrays are joined colon delimited like our path lists
+		parts := []string{}
+		for _, item := range v {
+			parts = append(parts, fmt.Sprintf("%v", item))
+		}
+		return strings.Join(parts, ":")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// ConfigInt returns key from section (or top level) of Config
+// as an int. Zero is returned if key isn't found or isn't a number.
+func ConfigInt(section, key string) int {
+	val, ok := ConfigValue(section, key)
+	if ok == false {
+		return 0
+	}
+	switch v := val.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
+
+// ConfigBool returns key from section (or top level) of Config
+// as a bool. False is returned if key isn't found.
+func ConfigBool(section, key string) bool {
+	val, ok := ConfigValue(section, key)
+	if ok == false {
+		return false
+	}
+	switch v := val.(type) {
+	case bool:
+		return v
+	case int:
+		return v == 1
+	case int64:
+		return v == 1
+	case float64:
+		return v == 1
+	case string:
+		return strings.ToLower(v) == "true"
+	}
+	return false
+}
+
+// ConfigData returns the "data" table from section (or top level)
+// of Config as key/value pairs suitable for ResolveData.
+func ConfigData(section string) map[string]string {
+	data := map[string]string{}
+	val, ok := ConfigValue(section, "data")
+	if ok == false {
+		return data
+	}
+	if m, ok := val.(map[string]interface{}); ok == true {
+		for k, v := range m {
+			data[k] = fmt.Sprintf("%v", v)
+		}
+	}
+	return data
+}
+
+// MergeConfig combines layers of configuration into a new map. Layers
+// are applied in order so later layers take precedence over earlier
+// ones. Processors merge DefaultConfig, Config, the document's front
+// matter and then ConfigOverrides (e.g. from the command line).
+//
+// Precedence rules
+//
+// + nested maps (e.g. "gomarkdown", "fountain") are merged key by key
+// + arrays and other values replace the earlier value entirely
+// + a nil layer is skipped
+//
+// None of the layers are modified.
+func MergeConfig(layers ...map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, layer := range layers {
+		mergeMap(out, layer)
+	}
+	return out
+}
+
+// mergeMap deep merges src into dest copying nested maps so
+// dest doesn't share state with src.
+func mergeMap(dest map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok == true {
+			if d, ok := dest[k].(map[string]interface{}); ok == true {
+				mergeMap(d, m)
+			} else {
+				d = map[string]interface{}{}
+				mergeMap(d, m)
+				dest[k] = d
+			}
+			continue
+		}
+		dest[k] = copyValue(v)
+	}
+}
+
+// copyValue returns a copy of maps and arrays, other values are
+// returned as is.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		mergeMap(m, val)
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, item := range val {
+			a[i] = copyValue(item)
+		}
+		return a
+	}
+	return v
+}
+
+// ParseConfigPairs takes a comma delimited list of key/value pairs
+// (e.g. "gomarkdown.Footnotes=true,fountain.AsHTMLPage=false") and
+// returns a map suitable for ConfigOverrides, see SetConfigPair.
+func ParseConfigPairs(s string) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if err := SetConfigPair(m, pair); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SetConfigPair adds a key/value pair (e.g. "gomarkdown.Footnotes=true")
+// to m. Dotted keys become nested maps, "true" and "false" become
+// bools, integers become int64 otherwise the value is kept as a
+// string. A value in double or single quotes (e.g. title="2020") is
+// always a string, the quotes are removed.
+func SetConfigPair(m map[string]interface{}, pair string) error {
+	kv := strings.SplitN(pair, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return fmt.Errorf("Can't read configuration pair %q", pair)
+	}
+	keys := strings.Split(strings.TrimSpace(kv[0]), ".")
+	val := strings.TrimSpace(kv[1])
+	var value interface{}
+	switch {
+	case len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0]:
+		value = val[1 : len(val)-1]
+	case strings.ToLower(val) == "true":
+		value = true
+	case strings.ToLower(val) == "false":
+		value = false
+	default:
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			value = i
+		} else {
+			value = val
+		}
+	}
+	cur := m
+	for _, k := range keys[0 : len(keys)-1] {
+		next, ok := cur[k].(map[string]interface{})
+		if ok == false {
+			next = map[string]interface{}{}
+			cur[k] = next
+		}
+		cur = next
+	}
+	cur[keys[len(keys)-1]] = value
+	return nil
+}
+
+// FlagValues returns each value given to a repeatable option in
+// args, e.g. both values of "-set draft=false -set weight=2", as the
+// flag package keeps only the last. Options after "--" are ignored.
+func FlagValues(args []string, name string) []string {
+	values := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		for _, prefix := range []string{"-" + name, "--" + name} {
+			switch {
+			case arg == prefix && i+1 < len(args):
+				i++
+				values = append(values, args[i])
+			case strings.HasPrefix(arg, prefix+"="):
+				values = append(values, strings.TrimPrefix(arg, prefix+"="))
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,157 @@
+//
+// config_test.go test routines for config.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dName := path.Join("testdata", "site")
+	fName := FindConfig(dName)
+	if fName != path.Join(dName, "mkpage.json") {
+		t.Errorf("expected to find mkpage.json in %s, got %q", dName, fName)
+		t.FailNow()
+	}
+	if err := LoadConfig(dName); err != nil {
+		t.Errorf("LoadConfig(%q) error %s", dName, err)
+		t.FailNow()
+	}
+
+	// Section values take precedence over top level
+	expected := "This Great Beyond"
+	if s := ConfigString("mkrss", "channel-title"); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	// Top level values are used when section is missing the key
+	expected = "http://blog.example.org"
+	if s := ConfigString("mkrss", "url"); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if s := ConfigString("sitemapper", "url"); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if i := ConfigInt("mkrss", "count"); i != 10 {
+		t.Errorf("expected 10, got %d", i)
+	}
+	if s := ConfigString("mkrss", "missing"); s != "" {
+		t.Errorf("expected empty string, got %q", s)
+	}
+	data := ConfigData("mkpage")
+	if val, ok := data["title"]; ok == false || val != "text:My Site" {
+		t.Errorf("expected title text:My Site, got %+v", data)
+	}
+
+	// A directory without a site config leaves Config empty
+	if err := LoadConfig("testdata"); err != nil {
+		t.Errorf("expected no error for missing site config, got %s", err)
+	}
+	if len(Config) != 0 {
+		t.Errorf("expected an empty Config, got %+v", Config)
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	site := map[string]interface{}{
+		"markup": "gomarkdown",
+		"gomarkdown": map[string]interface{}{
+			"Footnotes": true,
+			"Tables":    true,
+		},
+		"keywords": []interface{}{"site"},
+	}
+	frontMatter := map[string]interface{}{
+		"gomarkdown": map[string]interface{}{
+			"Tables": false,
+		},
+		"keywords": []interface{}{"post", "draft"},
+	}
+	overrides := map[string]interface{}{
+		"markup": "mmark",
+	}
+	m := MergeConfig(DefaultConfig, site, nil, frontMatter, overrides)
+
+	// Later layers replace scalars
+	if m["markup"] != "mmark" {
+		t.Errorf("expected markup mmark, got %v", m["markup"])
+	}
+	// Nested maps are merged key by key
+	gm, ok := m["gomarkdown"].(map[string]interface{})
+	if ok == false {
+		t.Errorf("expected gomarkdown map, got %T", m["gomarkdown"])
+		t.FailNow()
+	}
+	if gm["Footnotes"] != true || gm["Tables"] != false {
+		t.Errorf("expected Footnotes true and Tables false, got %+v", gm)
+	}
+	if _, ok := m["fountain"]; ok == false {
+		t.Errorf("expected fountain defaults in merged config")
+	}
+	// Arrays are replaced, not appended
+	if keywords, ok := m["keywords"].([]interface{}); ok == false || len(keywords) != 2 {
+		t.Errorf("expected keywords from front matter, got %+v", m["keywords"])
+	}
+	// Layers are not modified
+	if site["gomarkdown"].(map[string]interface{})["Tables"] != true {
+		t.Errorf("site layer was modified, %+v", site)
+	}
+	gm["Footnotes"] = false
+	if site["gomarkdown"].(map[string]interface{})["Footnotes"] != true {
+		t.Errorf("merged map shares state with site layer")
+	}
+}
+
+func TestParseConfigPairs(t *testing.T) {
+	m, err := ParseConfigPairs("gomarkdown.Footnotes=true, fountain.IncludeCSS=css/screenplay.css,depth=2")
+	if err != nil {
+		t.Errorf("ParseConfigPairs() error %s", err)
+		t.FailNow()
+	}
+	src, _ := json.Marshal(m)
+	expected := `{"depth":2,"fountain":{"IncludeCSS":"css/screenplay.css"},"gomarkdown":{"Footnotes":true}}`
+	if string(src) != expected {
+		t.Errorf("expected %s, got %s", expected, src)
+	}
+	if _, err := ParseConfigPairs("Footnotes"); err == nil {
+		t.Errorf("expected an error for a pair missing a value")
+	}
+
+	m = map[string]interface{}{}
+	for _, pair := range []string{`title="2020"`, "tags=a,b", "weight=2", "draft='true'", `note="`} {
+		if err := SetConfigPair(m, pair); err != nil {
+			t.Errorf("SetConfigPair(%q) error %s", pair, err)
+		}
+	}
+	src, _ = json.Marshal(m)
+	expected = `{"draft":"true","note":"\"","tags":"a,b","title":"2020","weight":2}`
+	if string(src) != expected {
+		t.Errorf("expected %s, got %s", expected, src)
+	}
+}
+
+func TestFlagValues(t *testing.T) {
+	args := []string{"-set", "a=1", "-delete", "b", "--set=c=x,y", "-set=d=2", "doc.md", "--", "-set", "e=3"}
+	values := FlagValues(args, "set")
+	if strings.Join(values, " ") != "a=1 c=x,y d=2" {
+		t.Errorf("unexpected values %+v", values)
+	}
+}
@@ -0,0 +1,821 @@
+//
+// Package mkpage feed.go describes syndication feeds independent of
+// their format and renders them as RSS 2.0, Atom 1.0 or JSON Feed 1.1.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Feed formats
+
+	// FeedRSS is an RSS 2.0 feed
+	FeedRSS = "rss"
+	// FeedAtom is an Atom 1.0 feed, see RFC 4287
+	FeedAtom = "atom"
+	// FeedJSON is a JSON Feed 1.1, see https://jsonfeed.org/version/1.1
+	FeedJSON = "jsonfeed"
+
+	// AtomNamespace is the XML namespace of Atom 1.0
+	AtomNamespace = "http://www.w3.org/2005/Atom"
+	// JSONFeedVersion identifies JSON Feed 1.1 documents
+	JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+	// ContentNamespace is the RSS content module's namespace, used
+	// for content:encoded
+	ContentNamespace = "http://purl.org/rss/1.0/modules/content/"
+	// ITunesNamespace is the iTunes podcast namespace, used for
+	// itunes:duration, itunes:image, etc.
+	ITunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+)
+
+var (
+	// mediaTypes are the MIME types of common podcast files, used
+	// before the system's types which may not include them
+	mediaTypes = map[string]string{
+		".mp3":  "audio/mpeg",
+		".m4a":  "audio/x-m4a",
+		".mp4":  "video/mp4",
+		".m4v":  "video/x-m4v",
+		".mov":  "video/quicktime",
+		".ogg":  "audio/ogg",
+		".oga":  "audio/ogg",
+		".opus": "audio/ogg",
+		".wav":  "audio/wav",
+		".pdf":  "application/pdf",
+	}
+
+	moreExp      = regexp.MustCompile(`(?i)<!--\s*more\s*-->`)
+	paragraphExp = regexp.MustCompile(`(?is)<p(\s[^>]*)?>(.*?)</p>`)
+)
+
+// Feed describes a channel of articles, e.g. a blog, independent
+// of the format it is published in.
+type Feed struct {
+	// Title of the feed
+	Title string
+	// Link is the website the feed describes, it is also used
+	// as the feed's Atom id
+	Link string
+	// FeedURL is where the feed itself is published, used for
+	// Atom's rel="self" link
+	FeedURL string
+	// Description of the feed
+	Description string
+	// Language of the feed, e.g. en-us
+	Language string
+	// Copyright (Atom rights) statement
+	Copyright string
+	// Category of the feed
+	Category string
+	// Generator names the program producing the feed
+	Generator string
+	// Author of the feed, if empty the feed's title is used
+	// where a format requires an author
+	Author string
+	// Image is the URL of the feed's artwork (podcast itunes:image)
+	Image string
+	// PubDate is when the feed was published
+	PubDate time.Time
+	// Updated is when the feed was last built, if zero the most
+	// recent item's date is used
+	Updated time.Time
+	// Items are the feed's articles
+	Items []*FeedItem
+}
+
+// FeedItem describes an article in a Feed.
+type FeedItem struct {
+	// ID is a permanent identifier for the item (RSS guid, Atom
+	// and JSON Feed id), defaults to Link
+	ID string
+	// Title of the article
+	Title string
+	// Link is the URL of the article
+	Link string
+	// Author of the article
+	Author string
+	// Summary is a short description of the article
+	Summary string
+	// Content is the article rendered as HTML
+	Content string
+	// Tags are the article's tags (JSON Feed) or categories
+	Tags []string
+	// Published is when the article was published
+	Published time.Time
+	// Updated is when the article was last modified, defaults
+	// to Published
+	Updated time.Time
+	// Enclosure is a media file attached to the article, e.g.
+	// the audio of a talk
+	Enclosure *Enclosure
+	// Image is the URL of the article's artwork (itunes:image)
+	Image string
+	// Explicit is "true" or "false" if the article declares
+	// whether it has explicit content (itunes:explicit)
+	Explicit string
+}
+
+// Enclosure describes a media file attached to a FeedItem.
+type Enclosure struct {
+	// URL of the media file, a local file path until resolved
+	URL string
+	// Type is the media file's MIME type, e.g. audio/mpeg
+	Type string
+	// Length is the media file's size in bytes
+	Length int64
+	// Duration is the play time, e.g. "32:15" (itunes:duration)
+	Duration string
+}
+
+// rssGUID is an RSS item's guid
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssCDATA holds HTML as character data, e.g. content:encoded
+type rssCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+// rssEnclosure is an RSS item's enclosure
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// itunesImage is an itunes:image
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// itunesCategory is an itunes:category
+type itunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+// rssItem is an RSS 2.0 item
+type rssItem struct {
+	Title          string        `xml:"title,omitempty"`
+	Link           string        `xml:"link,omitempty"`
+	Description    string        `xml:"description,omitempty"`
+	Author         string        `xml:"author,omitempty"`
+	Categories     []string      `xml:"category"`
+	GUID           *rssGUID      `xml:"guid,omitempty"`
+	PubDate        string        `xml:"pubDate,omitempty"`
+	Enclosure      *rssEnclosure `xml:"enclosure,omitempty"`
+	ITunesDuration string        `xml:"itunes:duration,omitempty"`
+	ITunesImage    *itunesImage  `xml:"itunes:image,omitempty"`
+	ITunesExplicit string        `xml:"itunes:explicit,omitempty"`
+	Content        *rssCDATA     `xml:"content:encoded,omitempty"`
+}
+
+// rssFeed is an RSS 2.0 document, the iTunes fields are
+// only included for podcasts
+type rssFeed struct {
+	XMLName        xml.Name        `xml:"rss"`
+	Version        string          `xml:"version,attr"`
+	ContentNS      string          `xml:"xmlns:content,attr,omitempty"`
+	ITunesNS       string          `xml:"xmlns:itunes,attr,omitempty"`
+	Title          string          `xml:"channel>title"`
+	Link           string          `xml:"channel>link"`
+	Description    string          `xml:"channel>description"`
+	Language       string          `xml:"channel>language,omitempty"`
+	Copyright      string          `xml:"channel>copyright,omitempty"`
+	PubDate        string          `xml:"channel>pubDate,omitempty"`
+	LastBuildDate  string          `xml:"channel>lastBuildDate,omitempty"`
+	Category       string          `xml:"channel>category,omitempty"`
+	Generator      string          `xml:"channel>generator,omitempty"`
+	ITunesAuthor   string          `xml:"channel>itunes:author,omitempty"`
+	ITunesCategory *itunesCategory `xml:"channel>itunes:category,omitempty"`
+	ITunesImage    *itunesImage    `xml:"channel>itunes:image,omitempty"`
+	Items          []*rssItem      `xml:"channel>item"`
+}
+
+// atomText is an Atom text construct, e.g. content
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+// atomLink is an Atom link
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+// atomPerson is an Atom person construct, e.g. author
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// atomCategory is an Atom category
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomEntry is an Atom entry
+type atomEntry struct {
+	ID         string          `xml:"id"`
+	Title      string          `xml:"title"`
+	Updated    string          `xml:"updated"`
+	Published  string          `xml:"published,omitempty"`
+	Author     *atomPerson     `xml:"author,omitempty"`
+	Links      []*atomLink     `xml:"link"`
+	Categories []*atomCategory `xml:"category"`
+	Summary    *atomText       `xml:"summary,omitempty"`
+	Content    *atomText       `xml:"content,omitempty"`
+}
+
+// atomFeed is an Atom feed document
+type atomFeed struct {
+	XMLName   xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang      string        `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Subtitle  string        `xml:"subtitle,omitempty"`
+	Updated   string        `xml:"updated"`
+	Author    *atomPerson   `xml:"author,omitempty"`
+	Links     []*atomLink   `xml:"link"`
+	Category  *atomCategory `xml:"category,omitempty"`
+	Rights    string        `xml:"rights,omitempty"`
+	Generator string        `xml:"generator,omitempty"`
+	Entries   []*atomEntry  `xml:"entry"`
+}
+
+// plainText returns HTML as text with its whitespace collapsed.
+func plainText(src string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagExp.ReplaceAllString(src, " "))), " ")
+}
+
+// Summarize returns a plain text summary of HTML content, the text
+// before a <!--more--> separator or else the first paragraph.
+func Summarize(content string) string {
+	if loc := moreExp.FindStringIndex(content); loc != nil {
+		return plainText(content[0:loc[0]])
+	}
+	if m := paragraphExp.FindStringSubmatch(content); m != nil {
+		return plainText(m[2])
+	}
+	return ""
+}
+
+// frontMatterNames returns the names in a front matter creator or
+// author value. Names can be strings or tables with a "name" or
+// "given_name" and "family_name", e.g.
+// creator = [{ given_name = "Jane", family_name = "Doe" }].
+func frontMatterNames(v interface{}) []string {
+	names := []string{}
+	switch val := v.(type) {
+	case string:
+		if s := strings.TrimSpace(val); s != "" {
+			names = append(names, s)
+		}
+	case map[string]interface{}:
+		if s, ok := val["name"].(string); ok == true && strings.TrimSpace(s) != "" {
+			names = append(names, strings.TrimSpace(s))
+		} else {
+			given, _ := val["given_name"].(string)
+			family, _ := val["family_name"].(string)
+			if s := strings.TrimSpace(given + " " + family); s != "" {
+				names = append(names, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			names = append(names, frontMatterNames(item)...)
+		}
+	case []string:
+		for _, item := range val {
+			names = append(names, frontMatterNames(item)...)
+		}
+	}
+	return names
+}
+
+// frontMatterEnclosure returns the Enclosure described by a front
+// matter value, either a file path or URL or a table with a "file"
+// (or "url"), "type", "length" and "duration".
+func frontMatterEnclosure(v interface{}) *Enclosure {
+	enc := new(Enclosure)
+	switch val := v.(type) {
+	case string:
+		enc.URL = strings.TrimSpace(val)
+	case map[string]interface{}:
+		for _, key := range []string{"file", "url", "path"} {
+			if s, ok := val[key].(string); ok == true && strings.TrimSpace(s) != "" {
+				enc.URL = strings.TrimSpace(s)
+				break
+			}
+		}
+		enc.Type, _ = val["type"].(string)
+		switch n := val["length"].(type) {
+		case float64:
+			enc.Length = int64(n)
+		case int64:
+			enc.Length = n
+		case int:
+			enc.Length = int64(n)
+		}
+		switch d := val["duration"].(type) {
+		case string:
+			enc.Duration = d
+		case float64, int64, int:
+			// NOTE: a number is the duration in seconds
+			enc.Duration = fmt.Sprintf("%v", d)
+		}
+	}
+	if enc.URL == "" {
+		return nil
+	}
+	return enc
+}
+
+// frontMatterExplicit returns "true" or "false" for a front matter
+// explicit value, e.g. explicit = false or explicit = "yes".
+func frontMatterExplicit(v interface{}) string {
+	switch val := v.(type) {
+	case bool:
+		return fmt.Sprintf("%t", val)
+	case string:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true", "yes", "explicit":
+			return "true"
+		case "false", "no", "clean":
+			return "false"
+		}
+	}
+	return ""
+}
+
+// ReadFeedItem reads the document fName returning a FeedItem
+// describing it along with its front matter. Front matter fields
+// (guid, title, creator or author, date, lastmod, description,
+// keywords, tags, enclosure, image and explicit) are preferred, otherwise the title, byline and date are
+// found in the document with the regular expressions titleExp,
+// bylineExp and dateExp (see TitleExp, BylineExp and DateExp). An
+// error is returned if the document can't be read or no date is
+// found. The item's Link and Content are left for the caller.
+func ReadFeedItem(fName string, titleExp string, bylineExp string, dateExp string) (*FeedItem, map[string]interface{}, error) {
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return nil, nil, err
+	}
+	configType, frontMatterSrc, body, err := SplitFrontMatterWithError(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Can't read front matter, %s", err)
+	}
+	if frontMatter == nil {
+		frontMatter = map[string]interface{}{}
+	}
+	item := new(FeedItem)
+	item.ID, _ = frontMatter["guid"].(string)
+	item.Title, _ = frontMatter["title"].(string)
+	item.Summary, _ = frontMatter["description"].(string)
+	names := frontMatterNames(frontMatter["creator"])
+	if len(names) == 0 {
+		names = frontMatterNames(frontMatter["author"])
+	}
+	item.Author = strings.Join(names, ", ")
+	for _, key := range []string{"keywords", "tags"} {
+		for _, tag := range FrontMatterList(frontMatter[key]) {
+			found := false
+			for _, s := range item.Tags {
+				if s == tag {
+					found = true
+					break
+				}
+			}
+			if found == false {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	}
+	item.Enclosure = frontMatterEnclosure(frontMatter["enclosure"])
+	item.Image, _ = frontMatter["image"].(string)
+	item.Explicit = frontMatterExplicit(frontMatter["explicit"])
+	dateValue, hasDate := frontMatter["date"]
+	if dt, ok := frontMatterDate(dateValue); ok == true {
+		item.Published = dt
+	} else if hasDate {
+		return nil, frontMatter, fmt.Errorf("Can't parse date %v", dateValue)
+	}
+	if dt, ok := frontMatterDate(frontMatterKey(frontMatter, "lastmod")); ok == true {
+		item.Updated = dt
+	}
+
+	// Fallback to the title and byline conventions
+	text := string(body)
+	if item.Title == "" {
+		item.Title = strings.TrimSpace(strings.TrimPrefix(Grep(titleExp, text), "# "))
+	}
+	if item.Author == "" || item.Published.IsZero() {
+		byline := Grep(bylineExp, text)
+		pubDate := Grep(dateExp, byline)
+		if item.Author == "" && len(byline) > 2 {
+			item.Author = strings.TrimSpace(strings.TrimSuffix(byline[2:], pubDate))
+		}
+		if item.Published.IsZero() {
+			if pubDate == "" {
+				return nil, frontMatter, fmt.Errorf("Can't find a date in front matter or byline")
+			}
+			dt, err := time.Parse(`2006-01-02`, pubDate)
+			if err != nil {
+				return nil, frontMatter, fmt.Errorf("Can't parse byline date %q, %s", pubDate, err)
+			}
+			item.Published = dt
+		}
+	}
+	return item, frontMatter, nil
+}
+
+// isURL returns true for http and https URLs.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// mediaURL returns the local path and URL of a file named in a
+// document's front matter. Names starting with "/" are relative to
+// docRoot, others to the document's directory.
+func mediaURL(name string, docName string, docRoot string, baseURL string) (string, string) {
+	fName := filepath.Join(filepath.Dir(docName), filepath.FromSlash(name))
+	if strings.HasPrefix(name, "/") {
+		fName = filepath.Join(docRoot, filepath.FromSlash(name))
+	}
+	rel, err := filepath.Rel(docRoot, fName)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(fName)
+	}
+	return fName, strings.TrimSuffix(baseURL, "/") + "/" + filepath.ToSlash(rel)
+}
+
+// ResolveMedia turns the local file paths of the item's Enclosure
+// and Image into URLs under baseURL (see ReadFeedItem). Paths
+// starting with "/" are relative to docRoot, others to the directory
+// of the document docName. The enclosure's Length is the size of
+// its file and its Type, if not set, is guessed from its extension.
+func (item *FeedItem) ResolveMedia(docName string, docRoot string, baseURL string) error {
+	if enc := item.Enclosure; enc != nil {
+		if isURL(enc.URL) == false {
+			fName, u := mediaURL(enc.URL, docName, docRoot, baseURL)
+			info, err := os.Stat(fName)
+			if err != nil {
+				return fmt.Errorf("Can't read enclosure, %s", err)
+			}
+			enc.URL, enc.Length = u, info.Size()
+		}
+		if enc.Type == "" {
+			ext := strings.ToLower(path.Ext(enc.URL))
+			if enc.Type = mediaTypes[ext]; enc.Type == "" {
+				enc.Type = mime.TypeByExtension(ext)
+			}
+		}
+		if enc.Type == "" {
+			enc.Type = "application/octet-stream"
+		}
+	}
+	if item.Image != "" && isURL(item.Image) == false {
+		_, item.Image = mediaURL(item.Image, docName, docRoot, baseURL)
+	}
+	return nil
+}
+
+// jsonFeedAuthor is a JSON Feed author
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonFeedItem is a JSON Feed item
+type jsonFeedItem struct {
+	ID            string            `json:"id"`
+	URL           string            `json:"url,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	ContentHTML   string            `json:"content_html,omitempty"`
+	ContentText   string            `json:"content_text,omitempty"`
+	Summary       string            `json:"summary,omitempty"`
+	DatePublished string            `json:"date_published,omitempty"`
+	DateModified  string            `json:"date_modified,omitempty"`
+	Image         string            `json:"image,omitempty"`
+	Authors       []*jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Attachments   []*jsonFeedAttach `json:"attachments,omitempty"`
+}
+
+// jsonFeedAttach is a JSON Feed attachment
+type jsonFeedAttach struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// jsonFeed is a JSON Feed document
+type jsonFeed struct {
+	Version     string            `json:"version"`
+	Title       string            `json:"title"`
+	HomePageURL string            `json:"home_page_url,omitempty"`
+	FeedURL     string            `json:"feed_url,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Language    string            `json:"language,omitempty"`
+	Icon        string            `json:"icon,omitempty"`
+	Authors     []*jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []*jsonFeedItem   `json:"items"`
+}
+
+// itemID returns the item's ID or its Link.
+func (item *FeedItem) itemID() string {
+	if item.ID != "" {
+		return item.ID
+	}
+	return item.Link
+}
+
+// HasTag returns true if the item has any of tags, ignoring case.
+func (item *FeedItem) HasTag(tags ...string) bool {
+	for _, tag := range tags {
+		for _, s := range item.Tags {
+			if strings.EqualFold(s, strings.TrimSpace(tag)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SortItems orders the feed's items newest first by their Published
+// date, items published at the same time keep their order.
+func (feed *Feed) SortItems() {
+	sort.SliceStable(feed.Items, func(i, j int) bool {
+		return feed.Items[i].Published.After(feed.Items[j].Published)
+	})
+}
+
+// itemUpdated returns when the item was last updated.
+func (item *FeedItem) itemUpdated() time.Time {
+	if item.Updated.IsZero() {
+		return item.Published
+	}
+	return item.Updated
+}
+
+// lastUpdated returns Updated or the most recent item's update,
+// if neither are known the current time is used.
+func (feed *Feed) lastUpdated() time.Time {
+	updated := feed.Updated
+	if updated.IsZero() {
+		for _, item := range feed.Items {
+			if dt := item.itemUpdated(); dt.After(updated) {
+				updated = dt
+			}
+		}
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	return updated
+}
+
+// RSS renders the feed as an RSS 2.0 document. Each item has a guid,
+// its ID or else its Link (a permalink). If content is true items
+// include their Content as content:encoded. Items with an Enclosure
+// make the feed a podcast including the iTunes channel fields
+// (itunes:author, itunes:category and itunes:image).
+func (feed *Feed) RSS(content bool) ([]byte, error) {
+	doc := &rssFeed{
+		Version:     "2.0",
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		Language:    feed.Language,
+		Copyright:   feed.Copyright,
+		Category:    feed.Category,
+		Generator:   feed.Generator,
+	}
+	// NOTE: RSS uses RFC 822 dates, RFC 1123 is its four digit year form
+	if feed.PubDate.IsZero() == false {
+		doc.PubDate = feed.PubDate.Format(time.RFC1123)
+	}
+	doc.LastBuildDate = feed.lastUpdated().Format(time.RFC1123)
+	if content {
+		doc.ContentNS = ContentNamespace
+	}
+	// A feed with enclosures is a podcast
+	podcast := false
+	for _, item := range feed.Items {
+		if item.Enclosure != nil || item.Image != "" || item.Explicit != "" {
+			doc.ITunesNS = ITunesNamespace
+		}
+		if item.Enclosure != nil {
+			podcast = true
+		}
+	}
+	if podcast {
+		doc.ITunesNS = ITunesNamespace
+		doc.ITunesAuthor = feed.Author
+		if feed.Category != "" {
+			doc.ITunesCategory = &itunesCategory{Text: feed.Category}
+		}
+		if feed.Image != "" {
+			doc.ITunesImage = &itunesImage{Href: feed.Image}
+		}
+	}
+	for _, item := range feed.Items {
+		entry := &rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Summary,
+			Author:      item.Author,
+			Categories:  item.Tags,
+		}
+		if id := item.itemID(); id != "" {
+			entry.GUID = &rssGUID{IsPermaLink: "false", Value: id}
+			if id == item.Link {
+				entry.GUID.IsPermaLink = "true"
+			}
+		}
+		if item.Published.IsZero() == false {
+			entry.PubDate = item.Published.Format(time.RFC1123)
+		}
+		if enc := item.Enclosure; enc != nil {
+			entry.Enclosure = &rssEnclosure{URL: enc.URL, Length: enc.Length, Type: enc.Type}
+			entry.ITunesDuration = enc.Duration
+		}
+		if item.Image != "" {
+			entry.ITunesImage = &itunesImage{Href: item.Image}
+		}
+		entry.ITunesExplicit = item.Explicit
+		if content && item.Content != "" {
+			entry.Content = &rssCDATA{Value: item.Content}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+	src, err := xml.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), src...), nil
+}
+
+// Atom renders the feed as an Atom 1.0 document. Every entry has an
+// id, updated date and alternate link. A feed level author is included
+// when Author is set or an entry has no author of its own.
+func (feed *Feed) Atom() ([]byte, error) {
+	if feed.Link == "" {
+		return nil, fmt.Errorf("Can't create an Atom feed without a link")
+	}
+	updated := feed.lastUpdated()
+	doc := &atomFeed{
+		Lang:      feed.Language,
+		ID:        feed.Link,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Updated:   updated.Format(time.RFC3339),
+		Rights:    feed.Copyright,
+		Generator: feed.Generator,
+	}
+	doc.Links = append(doc.Links, &atomLink{Rel: "alternate", Type: "text/html", Href: feed.Link})
+	if feed.FeedURL != "" {
+		doc.Links = append(doc.Links, &atomLink{Rel: "self", Type: "application/atom+xml", Href: feed.FeedURL})
+	}
+	if feed.Category != "" {
+		doc.Category = &atomCategory{Term: feed.Category}
+	}
+	needsAuthor := feed.Author != ""
+	for _, item := range feed.Items {
+		if item.Link == "" {
+			return nil, fmt.Errorf("Can't create an Atom entry without a link, %q", item.Title)
+		}
+		entry := &atomEntry{
+			ID:    item.itemID(),
+			Title: item.Title,
+		}
+		if dt := item.itemUpdated(); dt.IsZero() == false {
+			entry.Updated = dt.Format(time.RFC3339)
+		} else {
+			entry.Updated = doc.Updated
+		}
+		if item.Published.IsZero() == false {
+			entry.Published = item.Published.Format(time.RFC3339)
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		} else {
+			needsAuthor = true
+		}
+		entry.Links = append(entry.Links, &atomLink{Rel: "alternate", Type: "text/html", Href: item.Link})
+		if enc := item.Enclosure; enc != nil {
+			entry.Links = append(entry.Links, &atomLink{Rel: "enclosure", Type: enc.Type, Href: enc.URL, Length: enc.Length})
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, &atomCategory{Term: tag})
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: item.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	if needsAuthor {
+		name := feed.Author
+		if name == "" {
+			name = feed.Title
+		}
+		doc.Author = &atomPerson{Name: name}
+	}
+	src, err := xml.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), src...), nil
+}
+
+// JSONFeed renders the feed as a JSON Feed 1.1 document. Items
+// without Content use their Summary (or Title) as content_text
+// since JSON Feed requires one or the other.
+func (feed *Feed) JSONFeed() ([]byte, error) {
+	doc := &jsonFeed{
+		Version:     JSONFeedVersion,
+		Title:       feed.Title,
+		HomePageURL: feed.Link,
+		FeedURL:     feed.FeedURL,
+		Description: feed.Description,
+		Language:    feed.Language,
+		Icon:        feed.Image,
+		Items:       []*jsonFeedItem{},
+	}
+	if feed.Author != "" {
+		doc.Authors = []*jsonFeedAuthor{{Name: feed.Author}}
+	}
+	for _, item := range feed.Items {
+		id := item.itemID()
+		if id == "" {
+			return nil, fmt.Errorf("Can't create a JSON Feed item without an id or link, %q", item.Title)
+		}
+		entry := &jsonFeedItem{
+			ID:          id,
+			URL:         item.Link,
+			Title:       item.Title,
+			ContentHTML: item.Content,
+			Summary:     item.Summary,
+			Image:       item.Image,
+			Tags:        item.Tags,
+		}
+		if enc := item.Enclosure; enc != nil {
+			entry.Attachments = []*jsonFeedAttach{{URL: enc.URL, MimeType: enc.Type, SizeInBytes: enc.Length}}
+		}
+		if entry.ContentHTML == "" {
+			entry.ContentText = item.Summary
+			if entry.ContentText == "" {
+				entry.ContentText = item.Title
+			}
+		}
+		if item.Published.IsZero() == false {
+			entry.DatePublished = item.Published.Format(time.RFC3339)
+		}
+		if item.Updated.IsZero() == false {
+			entry.DateModified = item.Updated.Format(time.RFC3339)
+		}
+		if item.Author != "" {
+			entry.Authors = []*jsonFeedAuthor{{Name: item.Author}}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+	return json.MarshalIndent(doc, "", "    ")
+}
@@ -0,0 +1,525 @@
+//
+// feed_test.go test routines for feed.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedAtom(t *testing.T) {
+	feed := new(Feed)
+	feed.Title = "This Great Beyond"
+	feed.Description = "Blog to save the world"
+	feed.Link = "http://blog.example.org"
+	feed.FeedURL = "http://blog.example.org/atom.xml"
+	feed.Language = "en-us"
+	feed.Updated = time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC)
+	feed.Items = []*FeedItem{
+		{
+			Title:     "First Post",
+			Link:      "http://blog.example.org/2020/06/01/first.html",
+			Author:    "Jane Doe",
+			Summary:   "The first post",
+			Content:   "<p>Hello &amp; welcome</p>",
+			Published: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:     "Second Post",
+			Link:      "http://blog.example.org/2020/06/02/second.html",
+			Published: time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	src, err := feed.Atom()
+	if err != nil {
+		t.Errorf("Atom() error %s", err)
+		t.FailNow()
+	}
+	if strings.HasPrefix(string(src), "<?xml") == false || strings.Contains(string(src), `xmlns="http://www.w3.org/2005/Atom"`) == false {
+		t.Errorf("expected an Atom document, got\n%s", src)
+	}
+
+	// Read it back to check the required elements
+	doc := struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Links []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Updated   string `xml:"updated"`
+			Published string `xml:"published"`
+			Author    struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+			Link struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Summary string `xml:"summary"`
+			Content struct {
+				Type string `xml:"type,attr"`
+				Body string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}{}
+	if err := xml.Unmarshal(src, &doc); err != nil {
+		t.Errorf("Can't read Atom feed, %s\n%s", err, src)
+		t.FailNow()
+	}
+	if doc.ID != feed.Link || doc.Title != feed.Title || doc.Updated != "2020-06-02T10:00:00Z" {
+		t.Errorf("unexpected feed id, title or updated %+v", doc)
+	}
+	// The second entry has no author so the feed needs one
+	if doc.Author.Name != feed.Title {
+		t.Errorf("expected feed author %q, got %q", feed.Title, doc.Author.Name)
+	}
+	if len(doc.Links) != 2 || doc.Links[0].Rel != "alternate" || doc.Links[1].Rel != "self" || doc.Links[1].Href != feed.FeedURL {
+		t.Errorf("unexpected feed links %+v", doc.Links)
+	}
+	if len(doc.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(doc.Entries))
+		t.FailNow()
+	}
+	entry := doc.Entries[0]
+	if entry.ID != feed.Items[0].Link || entry.Updated != "2020-06-01T00:00:00Z" || entry.Published != entry.Updated {
+		t.Errorf("unexpected entry id or dates %+v", entry)
+	}
+	if entry.Author.Name != "Jane Doe" || entry.Link.Rel != "alternate" || entry.Link.Href != feed.Items[0].Link {
+		t.Errorf("unexpected entry author or link %+v", entry)
+	}
+	if entry.Summary != "The first post" || entry.Content.Type != "html" || entry.Content.Body != feed.Items[0].Content {
+		t.Errorf("unexpected entry summary or content %+v", entry)
+	}
+
+	feed.Link = ""
+	if _, err := feed.Atom(); err == nil {
+		t.Errorf("expected an error for a feed without a link")
+	}
+}
+
+func TestFeedJSON(t *testing.T) {
+	feed := new(Feed)
+	feed.Title = "This Great Beyond"
+	feed.Link = "http://blog.example.org"
+	feed.FeedURL = "http://blog.example.org/feed.json"
+	feed.Items = []*FeedItem{
+		{
+			Title:     "First Post",
+			Link:      "http://blog.example.org/2020/06/01/first.html",
+			Author:    "Jane Doe",
+			Summary:   "The first post",
+			Content:   "<p>Hello &amp; welcome</p>",
+			Tags:      []string{"go", "web"},
+			Published: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title: "Second Post",
+			Link:  "http://blog.example.org/2020/06/02/second.html",
+		},
+	}
+	src, err := feed.JSONFeed()
+	if err != nil {
+		t.Errorf("JSONFeed() error %s", err)
+		t.FailNow()
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Errorf("Can't read JSON Feed, %s\n%s", err, src)
+		t.FailNow()
+	}
+	if doc["version"] != JSONFeedVersion || doc["title"] != feed.Title || doc["home_page_url"] != feed.Link || doc["feed_url"] != feed.FeedURL {
+		t.Errorf("unexpected feed %s", src)
+	}
+	items, ok := doc["items"].([]interface{})
+	if ok == false || len(items) != 2 {
+		t.Errorf("expected 2 items, got %s", src)
+		t.FailNow()
+	}
+	item := items[0].(map[string]interface{})
+	expected := map[string]interface{}{
+		"id":             feed.Items[0].Link,
+		"url":            feed.Items[0].Link,
+		"title":          "First Post",
+		"content_html":   "<p>Hello &amp; welcome</p>",
+		"summary":        "The first post",
+		"date_published": "2020-06-01T00:00:00Z",
+	}
+	for k, v := range expected {
+		if item[k] != v {
+			t.Errorf("expected %s %q, got %q", k, v, item[k])
+		}
+	}
+	if authors, ok := item["authors"].([]interface{}); ok == false || len(authors) != 1 || authors[0].(map[string]interface{})["name"] != "Jane Doe" {
+		t.Errorf("unexpected authors %+v", item["authors"])
+	}
+	if tags, ok := item["tags"].([]interface{}); ok == false || len(tags) != 2 || tags[0] != "go" || tags[1] != "web" {
+		t.Errorf("unexpected tags %+v", item["tags"])
+	}
+	// Items need content_html or content_text
+	item = items[1].(map[string]interface{})
+	if item["content_text"] != "Second Post" || item["date_published"] != nil {
+		t.Errorf("unexpected second item %+v", item)
+	}
+}
+
+func TestReadFeedItem(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-feed")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	docs := map[string]string{
+		"front-matter.md": `{
+    "title": "Front Matter Title",
+    "creator": [
+        { "given_name": "Jane", "family_name": "Doe" },
+        { "name": "John Smith" }
+    ],
+    "date": "2020-06-01",
+    "lastmod": "2020-06-03",
+    "description": "All about front matter",
+    "keywords": ["go", "web"],
+    "tags": "web, feeds"
+}
+
+# Byline Title
+
+By Someone Else 2019-01-01
+`,
+		"byline.md": `
+# Byline Title
+
+By Jane Doe 2020-06-02
+
+Just a byline.
+`,
+		"mixed.md": `---
+author: Jane Doe
+---
+
+# Mixed Title
+
+By Someone Else 2020-06-04
+`,
+		"no-date.md": `
+# No Date
+
+Nothing to see here.
+`,
+		"bad-date.md": `---
+date: "June 1st"
+---
+
+# Bad Date
+`,
+	}
+	for fName, src := range docs {
+		if err := ioutil.WriteFile(path.Join(tmpDir, fName), []byte(src), 0664); err != nil {
+			t.Errorf("Can't write %s, %s", fName, err)
+			t.FailNow()
+		}
+	}
+
+	item, frontMatter, err := ReadFeedItem(path.Join(tmpDir, "front-matter.md"), TitleExp, BylineExp, DateExp)
+	if err != nil {
+		t.Errorf("ReadFeedItem() error %s", err)
+		t.FailNow()
+	}
+	if item.Title != "Front Matter Title" || item.Author != "Jane Doe, John Smith" || item.Summary != "All about front matter" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if item.Published.Equal(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)) == false || item.Updated.Equal(time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected dates %s and %s", item.Published, item.Updated)
+	}
+	if strings.Join(item.Tags, "|") != "go|web|feeds" {
+		t.Errorf("unexpected tags %+v", item.Tags)
+	}
+	if frontMatter["title"] != "Front Matter Title" {
+		t.Errorf("expected front matter, got %+v", frontMatter)
+	}
+
+	item, _, err = ReadFeedItem(path.Join(tmpDir, "byline.md"), TitleExp, BylineExp, DateExp)
+	if err != nil {
+		t.Errorf("ReadFeedItem() error %s", err)
+		t.FailNow()
+	}
+	if item.Title != "Byline Title" || item.Author != "Jane Doe" || item.Published.Equal(time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected item %+v", item)
+	}
+
+	// Front matter is preferred, the byline fills in the rest
+	item, _, err = ReadFeedItem(path.Join(tmpDir, "mixed.md"), TitleExp, BylineExp, DateExp)
+	if err != nil {
+		t.Errorf("ReadFeedItem() error %s", err)
+		t.FailNow()
+	}
+	if item.Title != "Mixed Title" || item.Author != "Jane Doe" || item.Published.Equal(time.Date(2020, 6, 4, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected item %+v", item)
+	}
+
+	for _, fName := range []string{"no-date.md", "bad-date.md", "missing.md"} {
+		if _, _, err := ReadFeedItem(path.Join(tmpDir, fName), TitleExp, BylineExp, DateExp); err == nil {
+			t.Errorf("expected an error for %s", fName)
+		}
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	feed := new(Feed)
+	feed.Title = "This Great Beyond"
+	feed.Description = "Blog to save the world"
+	feed.Link = "http://blog.example.org"
+	feed.PubDate = time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC)
+	feed.Updated = feed.PubDate
+	feed.Items = []*FeedItem{
+		{
+			Title:     "First Post",
+			Link:      "http://blog.example.org/2020/06/01/first.html",
+			Author:    "Jane Doe",
+			Summary:   "The first post",
+			Content:   "<p>Hello &amp; welcome</p>",
+			Tags:      []string{"go", "web"},
+			Published: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:    "urn:uuid:6b5c8e3a-0d1e-4f2a-9b7c-1a2b3c4d5e6f",
+			Title: "Second Post",
+			Link:  "http://blog.example.org/2020/06/02/second.html",
+		},
+	}
+	doc := struct {
+		Channel struct {
+			Title         string `xml:"title"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string   `xml:"title"`
+				Description string   `xml:"description"`
+				Categories  []string `xml:"category"`
+				GUID        struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+				Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}{}
+
+	for _, content := range []bool{false, true} {
+		src, err := feed.RSS(content)
+		if err != nil {
+			t.Errorf("RSS() error %s", err)
+			t.FailNow()
+		}
+		if err := xml.Unmarshal(src, &doc); err != nil {
+			t.Errorf("Can't read RSS feed, %s\n%s", err, src)
+			t.FailNow()
+		}
+		if doc.Channel.Title != feed.Title || doc.Channel.LastBuildDate != "Tue, 02 Jun 2020 10:00:00 UTC" {
+			t.Errorf("unexpected channel %+v", doc.Channel)
+		}
+		if len(doc.Channel.Items) != 2 {
+			t.Errorf("expected 2 items, got %s", src)
+			t.FailNow()
+		}
+		item := doc.Channel.Items[0]
+		if item.Description != "The first post" || strings.Join(item.Categories, "|") != "go|web" || item.PubDate != "Mon, 01 Jun 2020 00:00:00 UTC" {
+			t.Errorf("unexpected item %+v", item)
+		}
+		if item.GUID.Value != feed.Items[0].Link || item.GUID.IsPermaLink != "true" {
+			t.Errorf("expected the link as a permalink guid, got %+v", item.GUID)
+		}
+		if content && item.Content != feed.Items[0].Content {
+			t.Errorf("expected content:encoded, got %q\n%s", item.Content, src)
+		}
+		if content == false && strings.Contains(string(src), "content:encoded") {
+			t.Errorf("unexpected content:encoded\n%s", src)
+		}
+		item = doc.Channel.Items[1]
+		if item.GUID.Value != feed.Items[1].ID || item.GUID.IsPermaLink != "false" {
+			t.Errorf("expected the ID as the guid, got %+v", item.GUID)
+		}
+		doc.Channel.Items = nil
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	testData := []struct {
+		content  string
+		expected string
+	}{
+		{"<h1>Title</h1>\n<p>The <em>first</em>\nparagraph &amp; more.</p>\n<p>The second.</p>\n", "The first paragraph & more."},
+		{"<p>The first.</p>\n<p>The second.</p>\n<!-- more -->\n<p>The rest.</p>\n", "The first. The second."},
+		{"<h1>Just a title</h1>\n", ""},
+	}
+	for i, test := range testData {
+		if result := Summarize(test.content); result != test.expected {
+			t.Errorf("(%d) expected %q, got %q", i, test.expected, result)
+		}
+	}
+}
+
+func TestFeedSortItems(t *testing.T) {
+	feed := new(Feed)
+	feed.Items = []*FeedItem{
+		{Title: "old", Published: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"Biology"}},
+		{Title: "new", Published: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"physics", "web"}},
+		{Title: "middle-a", Published: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "middle-b", Published: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	feed.SortItems()
+	l := []string{}
+	for _, item := range feed.Items {
+		l = append(l, item.Title)
+	}
+	if result := strings.Join(l, ", "); result != "new, middle-a, middle-b, old" {
+		t.Errorf("unexpected order %s", result)
+	}
+	if feed.Items[3].HasTag("biology") == false || feed.Items[0].HasTag("chemistry", " Web") == false {
+		t.Errorf("expected tags to match ignoring case")
+	}
+	if feed.Items[1].HasTag("biology") || feed.Items[0].HasTag() {
+		t.Errorf("unexpected tag match")
+	}
+}
+
+func TestFeedEnclosure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-feed")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	postDir := path.Join(tmpDir, "2020", "06", "01")
+	os.MkdirAll(postDir, 0775)
+	os.MkdirAll(path.Join(tmpDir, "images"), 0775)
+	ioutil.WriteFile(path.Join(postDir, "talk.mp3"), []byte("0123456789"), 0664)
+	ioutil.WriteFile(path.Join(postDir, "talk.md"), []byte(`{
+    "title": "Our Latest Talk",
+    "date": "2020-06-01",
+    "image": "/images/talk.jpg",
+    "explicit": "no",
+    "enclosure": {
+        "file": "talk.mp3",
+        "duration": "32:15"
+    }
+}
+
+A talk.
+`), 0664)
+	ioutil.WriteFile(path.Join(postDir, "missing.md"), []byte(`{
+    "title": "Missing Audio",
+    "date": "2020-06-01",
+    "enclosure": "missing.mp3"
+}
+`), 0664)
+
+	item, _, err := ReadFeedItem(path.Join(postDir, "talk.md"), TitleExp, BylineExp, DateExp)
+	if err != nil {
+		t.Errorf("ReadFeedItem() error %s", err)
+		t.FailNow()
+	}
+	if err := item.ResolveMedia(path.Join(postDir, "talk.md"), tmpDir, "http://blog.example.org/"); err != nil {
+		t.Errorf("ResolveMedia() error %s", err)
+		t.FailNow()
+	}
+	enc := item.Enclosure
+	if enc == nil || enc.URL != "http://blog.example.org/2020/06/01/talk.mp3" || enc.Length != 10 || enc.Type != "audio/mpeg" || enc.Duration != "32:15" {
+		t.Errorf("unexpected enclosure %+v", enc)
+	}
+	if item.Image != "http://blog.example.org/images/talk.jpg" || item.Explicit != "false" {
+		t.Errorf("unexpected image or explicit %q, %q", item.Image, item.Explicit)
+	}
+
+	item, _, err = ReadFeedItem(path.Join(postDir, "missing.md"), TitleExp, BylineExp, DateExp)
+	if err != nil {
+		t.Errorf("ReadFeedItem() error %s", err)
+		t.FailNow()
+	}
+	if err := item.ResolveMedia(path.Join(postDir, "missing.md"), tmpDir, "http://blog.example.org"); err == nil {
+		t.Errorf("expected an error for a missing enclosure")
+	}
+
+	// A feed with an enclosure is a podcast
+	feed := new(Feed)
+	feed.Title = "Talks"
+	feed.Link = "http://blog.example.org"
+	feed.Author = "Jane Doe"
+	feed.Category = "Science"
+	feed.Image = "http://blog.example.org/images/talks.jpg"
+	feed.Items = []*FeedItem{
+		{
+			Title:     "Our Latest Talk",
+			Link:      "http://blog.example.org/2020/06/01/talk.html",
+			Published: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+			Enclosure: enc,
+			Image:     "http://blog.example.org/images/talk.jpg",
+			Explicit:  "false",
+		},
+	}
+	src, err := feed.RSS(false)
+	if err != nil {
+		t.Errorf("RSS() error %s", err)
+		t.FailNow()
+	}
+	for _, expected := range []string{
+		`xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"`,
+		`<itunes:author>Jane Doe</itunes:author>`,
+		`<itunes:category text="Science"></itunes:category>`,
+		`<itunes:image href="http://blog.example.org/images/talks.jpg"></itunes:image>`,
+		`<enclosure url="http://blog.example.org/2020/06/01/talk.mp3" length="10" type="audio/mpeg"></enclosure>`,
+		`<itunes:duration>32:15</itunes:duration>`,
+		`<itunes:image href="http://blog.example.org/images/talk.jpg"></itunes:image>`,
+		`<itunes:explicit>false</itunes:explicit>`,
+	} {
+		if strings.Contains(string(src), expected) == false {
+			t.Errorf("expected %s in\n%s", expected, src)
+		}
+	}
+	src, err = feed.Atom()
+	if err != nil {
+		t.Errorf("Atom() error %s", err)
+		t.FailNow()
+	}
+	if strings.Contains(string(src), `<link rel="enclosure" type="audio/mpeg" href="http://blog.example.org/2020/06/01/talk.mp3" length="10"></link>`) == false {
+		t.Errorf("expected an enclosure link in\n%s", src)
+	}
+	src, err = feed.JSONFeed()
+	if err != nil {
+		t.Errorf("JSONFeed() error %s", err)
+		t.FailNow()
+	}
+	if strings.Contains(string(src), `"mime_type": "audio/mpeg"`) == false || strings.Contains(string(src), `"size_in_bytes": 10`) == false {
+		t.Errorf("expected an attachment in\n%s", src)
+	}
+}
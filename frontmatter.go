@@ -0,0 +1,293 @@
+//
+// Package mkpage frontmatter.go provides editing and conversion of
+// a document's front matter leaving the body untouched.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+
+	// 3rd Party Packages
+	"github.com/BurntSushi/toml"
+	"github.com/ghodss/yaml"
+)
+
+const (
+	// Front matter formats, named by their fencing
+
+	// FrontMatterTOML is TOML fenced by "+++" as used by Hugo
+	FrontMatterTOML = "toml"
+	// FrontMatterMmark is TOML fenced by "%%%" as used by Mmark
+	FrontMatterMmark = "mmark"
+	// FrontMatterYAML is YAML fenced by "---"
+	FrontMatterYAML = "yaml"
+	// FrontMatterJSON is a JSON object starting the document
+	FrontMatterJSON = "json"
+)
+
+// FrontMatterFormat returns the format of the front matter in input,
+// an empty string is returned if there is no front matter.
+func FrontMatterFormat(input []byte) string {
+	input = bytes.TrimPrefix(input, byteOrderMark)
+	firstLine, _ := splitLine(input)
+	switch string(bytes.TrimRight(firstLine, " \t\r")) {
+	case "+++":
+		return FrontMatterTOML
+	case "%%%":
+		return FrontMatterMmark
+	case "---":
+		return FrontMatterYAML
+	}
+	if isJSONFence(firstLine) {
+		return FrontMatterJSON
+	}
+	return ""
+}
+
+// ReadFrontMatter returns the parsed front matter of the document
+// fName, an empty map if it has none.
+func ReadFrontMatter(fName string) (map[string]interface{}, error) {
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return nil, err
+	}
+	configType, frontMatterSrc, _, err := SplitFrontMatterWithError(src)
+	if err != nil {
+		return nil, err
+	}
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read front matter of %s, %s", fName, err)
+	}
+	if frontMatter == nil {
+		frontMatter = map[string]interface{}{}
+	}
+	return frontMatter, nil
+}
+
+// FrontMatterList returns a front matter value as a list of strings,
+// e.g. tags = ["go", "web"] or tags = "go, web".
+func FrontMatterList(v interface{}) []string {
+	l := []string{}
+	switch val := v.(type) {
+	case string:
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				l = append(l, s)
+			}
+		}
+	case []string:
+		for _, s := range val {
+			if s = strings.TrimSpace(s); s != "" {
+				l = append(l, s)
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok == true && strings.TrimSpace(s) != "" {
+				l = append(l, strings.TrimSpace(s))
+			}
+		}
+	}
+	return l
+}
+
+// wholeNumbers returns a copy of m with whole float64 values as
+// int64 so JSON and YAML numbers don't become TOML floats.
+func wholeNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1e15 {
+			return int64(val)
+		}
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range val {
+			m[k] = wholeNumbers(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(val))
+		for i, item := range val {
+			l[i] = wholeNumbers(item)
+		}
+		return l
+	}
+	return v
+}
+
+// EncodeFrontMatter renders m as front matter, including the fencing,
+// in format (e.g. FrontMatterYAML). An empty m results in no front
+// matter.
+func EncodeFrontMatter(format string, m map[string]interface{}) ([]byte, error) {
+	if len(m) == 0 {
+		return []byte{}, nil
+	}
+	var (
+		buf bytes.Buffer
+		src []byte
+		err error
+	)
+	switch format {
+	case FrontMatterTOML, FrontMatterMmark:
+		fence := "+++\n"
+		if format == FrontMatterMmark {
+			fence = "%%%\n"
+		}
+		buf.WriteString(fence)
+		if err = toml.NewEncoder(&buf).Encode(wholeNumbers(m)); err != nil {
+			return nil, fmt.Errorf("Can't encode TOML front matter, %s", err)
+		}
+		if bytes.HasSuffix(buf.Bytes(), []byte("\n")) == false {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(fence)
+	case FrontMatterYAML:
+		if src, err = yaml.Marshal(m); err != nil {
+			return nil, fmt.Errorf("Can't encode YAML front matter, %s", err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(src)
+		if bytes.HasSuffix(src, []byte("\n")) == false {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("---\n")
+	case FrontMatterJSON:
+		if src, err = json.MarshalIndent(m, "", "    "); err != nil {
+			return nil, fmt.Errorf("Can't encode JSON front matter, %s", err)
+		}
+		buf.Write(src)
+		buf.WriteString("\n")
+	default:
+		return nil, fmt.Errorf("Unknown front matter format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// FrontMatterRename renames the front matter key Old to New.
+type FrontMatterRename struct {
+	Old string
+	New string
+}
+
+// FrontMatterEdit describes changes to a document's front matter.
+// Keys may be dotted (e.g. "fountain.AsHTMLPage") to reach into
+// tables. Renames are applied first, in order, then deletes, then Set.
+type FrontMatterEdit struct {
+	// Set holds values to add or replace, see ParseConfigPairs
+	Set map[string]interface{}
+	// Delete lists keys to remove
+	Delete []string
+	// Rename lists keys to rename applied in the order given
+	Rename []FrontMatterRename
+	// Format converts the front matter to FrontMatterTOML,
+	// FrontMatterMmark, FrontMatterYAML or FrontMatterJSON. If empty
+	// the document's format is kept, documents without front matter
+	// get FrontMatterJSON.
+	Format string
+}
+
+// lookupKey finds the table holding a dotted key creating
+// tables along the way if create is true.
+func lookupKey(m map[string]interface{}, key string, create bool) (map[string]interface{}, string) {
+	parts := strings.Split(key, ".")
+	cur := m
+	for _, k := range parts[0 : len(parts)-1] {
+		next, ok := cur[k].(map[string]interface{})
+		if ok == false {
+			if create == false {
+				return nil, ""
+			}
+			next = map[string]interface{}{}
+			cur[k] = next
+		}
+		cur = next
+	}
+	return cur, parts[len(parts)-1]
+}
+
+// Apply edits the front matter of input returning the new document.
+// The document's body is left untouched, byte for byte, and new
+// front matter uses the line endings of the original. The boolean is
+// false, and input is returned, if the document is unchanged.
+func (edit *FrontMatterEdit) Apply(input []byte) ([]byte, bool, error) {
+	format := FrontMatterFormat(input)
+	configType, frontMatterSrc, body, err := splitFrontMatter(input)
+	if err != nil {
+		return nil, false, err
+	}
+	m, err := ProcessorConfig(configType, normalizeEOL(frontMatterSrc))
+	if err != nil {
+		return nil, false, err
+	}
+	if format == "" {
+		// NOTE: ProcessorConfig returns an empty map without front matter
+		m = map[string]interface{}{}
+	}
+	orig := MergeConfig(m)
+	for _, rename := range edit.Rename {
+		table, k := lookupKey(m, rename.Old, false)
+		if table == nil {
+			continue
+		}
+		if val, ok := table[k]; ok == true {
+			delete(table, k)
+			dest, newK := lookupKey(m, rename.New, true)
+			dest[newK] = val
+		}
+	}
+	for _, key := range edit.Delete {
+		if table, k := lookupKey(m, key, false); table != nil {
+			delete(table, k)
+		}
+	}
+	if len(edit.Set) > 0 {
+		m = MergeConfig(m, edit.Set)
+	}
+
+	target := edit.Format
+	if target == "" {
+		target = format
+	}
+	if target == "" {
+		target = FrontMatterJSON
+	}
+	// NOTE: re-encoding reorders keys so only rewrite when needed
+	if (format == target && reflect.DeepEqual(orig, m)) || (format == "" && len(m) == 0) {
+		return input, false, nil
+	}
+	src, err := EncodeFrontMatter(target, m)
+	if err != nil {
+		return nil, false, err
+	}
+	if firstLine, _ := splitLine(input); bytes.HasSuffix(firstLine, []byte("\r")) {
+		src = bytes.Replace(src, []byte("\n"), []byte("\r\n"), -1)
+	}
+	out := []byte{}
+	if bytes.HasPrefix(input, byteOrderMark) {
+		out = append(out, byteOrderMark...)
+	}
+	out = append(out, src...)
+	return append(out, body...), true, nil
+}
@@ -0,0 +1,192 @@
+//
+// frontmatter_test.go test routines for frontmatter.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFrontMatterEdit(t *testing.T) {
+	src := []byte(`---
+title: A Blog Post
+author: Jane Doe
+weight: 2
+---
+
+# A Blog Post
+
++++ not front matter +++
+`)
+	body := "\n# A Blog Post\n\n+++ not front matter +++\n"
+
+	// Convert YAML to each format, the body is untouched
+	for format, prefix := range map[string]string{
+		FrontMatterTOML:  "+++\n",
+		FrontMatterMmark: "%%%\n",
+		FrontMatterJSON:  "{\n",
+		FrontMatterYAML:  "---\n",
+	} {
+		edit := &FrontMatterEdit{Format: format}
+		out, changed, err := edit.Apply(src)
+		if err != nil {
+			t.Errorf("Apply() to %s error %s", format, err)
+			continue
+		}
+		if format == FrontMatterYAML {
+			if changed == true || string(out) != string(src) {
+				t.Errorf("expected YAML to YAML to be unchanged, got %s", out)
+			}
+			continue
+		}
+		if changed == false || strings.HasPrefix(string(out), prefix) == false || strings.HasSuffix(string(out), body) == false {
+			t.Errorf("expected %s front matter followed by the body, got %s", format, out)
+		}
+		if FrontMatterFormat(out) != format {
+			t.Errorf("expected format %s, got %q", format, FrontMatterFormat(out))
+		}
+		configType, frontMatterSrc, rest := SplitFrontMatter(out)
+		m, err := ProcessorConfig(configType, frontMatterSrc)
+		if err != nil {
+			t.Errorf("Can't read converted %s front matter, %s", format, err)
+			continue
+		}
+		if m["title"] != "A Blog Post" || m["author"] != "Jane Doe" {
+			t.Errorf("expected title and author in %s, got %+v", format, m)
+		}
+		if string(rest) != body {
+			t.Errorf("expected body %q in %s, got %q", body, format, rest)
+		}
+	}
+	for src, format := range map[string]string{
+		"{\"title\": \"One line\"}\n# Body\n": FrontMatterJSON,
+		"{{ .title }}\n":                      "",
+		"{.class}\n":                          "",
+	} {
+		if FrontMatterFormat([]byte(src)) != format {
+			t.Errorf("expected format %q for %q, got %q", format, src, FrontMatterFormat([]byte(src)))
+		}
+	}
+
+	// Set, delete and rename keys
+	set, _ := ParseConfigPairs("draft=false,gomarkdown.Footnotes=true")
+	edit := &FrontMatterEdit{
+		Set:    set,
+		Delete: []string{"weight"},
+		Rename: []FrontMatterRename{{Old: "author", New: "creator"}},
+		Format: FrontMatterJSON,
+	}
+	out, changed, err := edit.Apply(src)
+	if err != nil || changed == false {
+		t.Errorf("expected changes, %s", err)
+		t.FailNow()
+	}
+	configType, frontMatterSrc, _ := SplitFrontMatter(out)
+	m, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		t.Errorf("Can't read edited front matter, %s", err)
+		t.FailNow()
+	}
+	if _, ok := m["author"]; ok == true || m["creator"] != "Jane Doe" {
+		t.Errorf("expected author renamed to creator, got %+v", m)
+	}
+	if _, ok := m["weight"]; ok == true {
+		t.Errorf("expected weight to be deleted, got %+v", m)
+	}
+	if m["draft"] != false {
+		t.Errorf("expected draft false, got %+v", m)
+	}
+	if gm, ok := m["gomarkdown"].(map[string]interface{}); ok == false || gm["Footnotes"] != true {
+		t.Errorf("expected gomarkdown.Footnotes true, got %+v", m)
+	}
+
+	// Deleting a missing key leaves the document alone
+	edit = &FrontMatterEdit{Delete: []string{"missing"}}
+	if out, changed, _ := edit.Apply(src); changed == true || string(out) != string(src) {
+		t.Errorf("expected no change, got %s", out)
+	}
+
+	// Documents without front matter get JSON front matter
+	edit = &FrontMatterEdit{Set: map[string]interface{}{"draft": true}}
+	out, changed, err = edit.Apply([]byte("# Hello\n"))
+	if err != nil || changed == false || string(out) != "{\n    \"draft\": true\n}\n# Hello\n" {
+		t.Errorf("expected JSON front matter added, got %q, %s", out, err)
+	}
+}
+
+func TestFrontMatterList(t *testing.T) {
+	testData := []struct {
+		val      interface{}
+		expected string
+	}{
+		{"go, web ,", "go|web"},
+		{[]interface{}{"go", 1, " web "}, "go|web"},
+		{[]string{"go"}, "go"},
+		{nil, ""},
+	}
+	for i, test := range testData {
+		if result := strings.Join(FrontMatterList(test.val), "|"); result != test.expected {
+			t.Errorf("(%d) expected %q, got %q", i, test.expected, result)
+		}
+	}
+}
+
+func TestFrontMatterEditLineEndings(t *testing.T) {
+	src := []byte("---\r\ntitle: CRLF\r\n---\r\n# Body\r\n\r\nLine one\r\nLine two\r\n")
+	body := "# Body\r\n\r\nLine one\r\nLine two\r\n"
+
+	// No change returns the document as given
+	edit := &FrontMatterEdit{Delete: []string{"missing"}}
+	out, changed, err := edit.Apply(src)
+	if err != nil || changed == true || bytes.Equal(out, src) == false {
+		t.Errorf("expected %q unchanged, got %q, %v", src, out, err)
+	}
+
+	edit = &FrontMatterEdit{Set: map[string]interface{}{"draft": true}}
+	out, changed, err = edit.Apply(src)
+	if err != nil || changed == false {
+		t.Errorf("expected a change, %v", err)
+		t.FailNow()
+	}
+	if bytes.HasSuffix(out, []byte(body)) == false {
+		t.Errorf("expected body %q untouched, got %q", body, out)
+	}
+	if bytes.Count(out, []byte("\n")) != bytes.Count(out, []byte("\r\n")) {
+		t.Errorf("expected CRLF line endings throughout, got %q", out)
+	}
+	configType, frontMatterSrc, _ := SplitFrontMatter(out)
+	m, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil || m["title"] != "CRLF" || m["draft"] != true {
+		t.Errorf("unexpected front matter %+v, %v", m, err)
+	}
+
+	// Renames apply in order
+	src = []byte("{\n    \"a\": 1,\n    \"b\": 2\n}\n# Body\n")
+	edit = &FrontMatterEdit{Rename: []FrontMatterRename{{Old: "b", New: "c"}, {Old: "a", New: "b"}}}
+	for i := 0; i < 10; i++ {
+		out, _, err = edit.Apply(src)
+		configType, frontMatterSrc, _ = SplitFrontMatter(out)
+		m, err = ProcessorConfig(configType, frontMatterSrc)
+		if err != nil || len(m) != 2 || m["b"] != float64(1) || m["c"] != float64(2) {
+			t.Errorf("expected b = 1, c = 2, got %+v, %v", m, err)
+			break
+		}
+	}
+}
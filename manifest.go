@@ -0,0 +1,142 @@
+//
+// Package mkpage manifest.go records the inputs used to build a site
+// so later builds only render pages whose inputs have changed.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// ManifestName is the file written to a site's output directory
+	// recording the dependencies of each output file.
+	ManifestName = ".mkpage-manifest.json"
+
+	// Dependencies which aren't files or URLs are recorded with
+	// the following names.
+
+	// DefaultTemplateDep names the built-in page template
+	DefaultTemplateDep = "mkpage:page.tmpl"
+	// DataDep names the site's key/value data pairs
+	DataDep = "mkpage:data"
+	// ConfigDep names the merged site configuration and overrides
+	ConfigDep = "mkpage:config"
+)
+
+// ManifestEntry describes how an output file was built.
+type ManifestEntry struct {
+	// Source is the document or asset the output was built from
+	Source string `json:"source"`
+	// Templates lists the template files used to render a document
+	Templates []string `json:"templates,omitempty"`
+	// Dependencies maps each input (source, templates, files and URLs
+	// read by ResolveData) to a SHA-256 hash of its content.
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// Manifest holds a ManifestEntry for each output file keyed by
+// the output file's path relative to the output directory.
+type Manifest struct {
+	Version string                    `json:"version"`
+	Outputs map[string]*ManifestEntry `json:"outputs"`
+}
+
+// NewManifest returns an empty Manifest.
+func NewManifest() *Manifest {
+	return &Manifest{
+		Version: Version,
+		Outputs: map[string]*ManifestEntry{},
+	}
+}
+
+// ReadManifest reads a manifest file. A missing file results in
+// an empty manifest.
+func ReadManifest(fName string) (*Manifest, error) {
+	manifest := NewManifest()
+	src, err := ioutil.ReadFile(fName)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(src, &manifest); err != nil {
+		return NewManifest(), fmt.Errorf("Can't read manifest %s, %s", fName, err)
+	}
+	if manifest.Outputs == nil {
+		manifest.Outputs = map[string]*ManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// Write saves the manifest to fName as JSON.
+func (manifest *Manifest) Write(fName string) error {
+	manifest.Version = Version
+	src, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fName, src, 0664)
+}
+
+// hashBytes returns a hex encoded SHA-256 hash of buf.
+func hashBytes(buf []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(buf))
+}
+
+// hashValue returns a hash of the JSON encoding of a value,
+// maps are encoded with sorted keys so the hash is stable.
+func hashValue(v interface{}) string {
+	src, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(src)
+}
+
+// hashSource returns a hash of a file or URL's content. An empty
+// string is returned if the source can't be read.
+func hashSource(name string) string {
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		resp, err := http.Get(name)
+		if err != nil {
+			return ""
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return ""
+		}
+		buf, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return ""
+		}
+		return hashBytes(buf)
+	}
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(buf)
+}
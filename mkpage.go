@@ -31,6 +31,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -129,8 +130,28 @@ var (
 	// Config holds a global config.
 	// Uses the same structure as Front Matter in that it is
 	// the result of parsing TOML, YAML or JSON into a
-	// map[string]interface{} tree
+	// map[string]interface{} tree. It is populated from
+	// mkpage.toml, mkpage.json or mkpage.yaml by LoadConfig.
 	Config map[string]interface{}
+
+	// DefaultConfig holds the built-in processor settings. It is
+	// the first layer merged by the processors, see MergeConfig.
+	DefaultConfig = map[string]interface{}{
+		"fountain": map[string]interface{}{
+			"AsHTMLPage": false,
+			"InlineCSS":  false,
+			"LinkCSS":    false,
+		},
+	}
+
+	// ConfigOverrides holds configuration set on the command line,
+	// e.g. via ParseConfigPairs. It is the last layer merged by the
+	// processors so it overrides Config and front matter.
+	ConfigOverrides map[string]interface{}
+
+	// fountainMutex guards the fountain package's settings which
+	// are package level variables shared by every render.
+	fountainMutex sync.Mutex
 )
 
 // normalizeEOL takes a []byte and normalizes the end of line
@@ -147,41 +168,179 @@ func normalizeEOL(input []byte) []byte {
 	return input
 }
 
-// SplitFrontMatter takes a []byte input splits it into front matter
-// source and Markdown source. If either is missing an empty []byte
-// is returned for the missing element.
-func SplitFrontMatter(input []byte) (int, []byte, []byte) {
-	// YAML front matter uses ---, note this conflicts with Mmark practice, do I want to support YAML like this?
-	if bytes.HasPrefix(input, []byte("---\n")) {
-		parts := bytes.SplitN(bytes.TrimPrefix(input, []byte("---\n")), []byte("\n---\n"), 2)
-		return ConfigIsYAML, parts[0], parts[1]
+// FrontMatterError describes front matter which can't be split
+// from the rest of a document, e.g. a fence that is never closed.
+type FrontMatterError struct {
+	// Fence is the opening fence, e.g. "---", "+++", "%%%" or "{",
+	// for JSON it is the "{" or string left open
+	Fence string
+	// Line is the line number of the opening fence, for JSON the
+	// line of the innermost object or string left open
+	Line int
+	// EndLine is the last line read looking for the closing fence
+	EndLine int
+	// Message describes the problem
+	Message string
+}
+
+// Error returns a description of the problem including line numbers.
+func (e *FrontMatterError) Error() string {
+	return fmt.Sprintf("front matter %q opened on line %d %s (read to line %d)", e.Fence, e.Line, e.Message, e.EndLine)
+}
+
+// byteOrderMark is the UTF-8 BOM some editors add to the start of a file
+var byteOrderMark = []byte("\xEF\xBB\xBF")
+
+// splitLine returns the first line of src (without the newline) and
+// the rest of src following the newline.
+func splitLine(src []byte) ([]byte, []byte) {
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		return src[0:i], src[i+1:]
+	}
+	return src, []byte{}
+}
+
+// isJSONFence returns true if the first line of a document opens
+// JSON front matter, i.e. it starts with "{" followed by a quote,
+// whitespace or the end of the line. A body starting with a template
+// action ("{{"), or an attribute block ("{.class}", "{#id}") isn't
+// taken as JSON.
+func isJSONFence(firstLine []byte) bool {
+	if len(firstLine) == 0 || firstLine[0] != '{' {
+		return false
+	}
+	if len(firstLine) == 1 {
+		return true
+	}
+	switch firstLine[1] {
+	case '"', ' ', '\t', '\r':
+		return true
+	}
+	return false
+}
+
+// splitJSONFrontMatter finds the end of the JSON object starting
+// input. The closing brace doesn't need to be on a line of its own,
+// the rest of its line is skipped if it is blank.
+func splitJSONFrontMatter(input []byte) ([]byte, []byte, error) {
+	// NOTE: opened holds the line of each object not yet closed
+	opened := []int{}
+	line, stringLine := 1, 0
+	inString, escaped := false, false
+	for i, c := range input {
+		if c == '\n' {
+			line++
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+			stringLine = line
+		case inString:
+		case c == '{':
+			opened = append(opened, line)
+		case c == '}' && len(opened) > 0:
+			opened = opened[0 : len(opened)-1]
+			if len(opened) == 0 {
+				frontMatter := input[0 : i+1]
+				rest, body := splitLine(input[i+1:])
+				if len(bytes.TrimSpace(rest)) > 0 {
+					// NOTE: content follows the closing brace on the same line
+					body = input[i+1:]
+				}
+				return frontMatter, body, nil
+			}
+		}
 	}
-	// TOML front matter as used in Hugo
-	if bytes.HasPrefix(input, []byte("+++\n")) {
-		parts := bytes.SplitN(bytes.TrimPrefix(input, []byte("+++\n")), []byte("\n+++\n"), 2)
-		return ConfigIsTOML, parts[0], parts[1]
+	if inString {
+		return nil, nil, &FrontMatterError{Fence: `"`, Line: stringLine, EndLine: line, Message: "is never closed by a matching \""}
 	}
-	// TOML front matter identified in Mmark as three % or dashes,
-	// We can support the %, dashes are taken by Hugo style, but
-	// maybe I don't want to support that?
-	if bytes.HasPrefix(input, []byte("%%%\n")) {
-		parts := bytes.SplitN(bytes.TrimPrefix(input, []byte("%%%\n")), []byte("\n%%%\n"), 2)
-		return ConfigIsTOML, parts[0], parts[1]
+	return nil, nil, &FrontMatterError{Fence: "{", Line: opened[len(opened)-1], EndLine: line, Message: "is never closed by a matching }"}
+}
+
+// SplitFrontMatterWithError takes a []byte input splits it into
+// front matter source and Markdown source returning the front
+// matter type (e.g. ConfigIsYAML). If there is no front matter
+// ConfigIsUnknown, an empty []byte and input are returned.
+//
+// Front matter must start the document, a leading byte order mark
+// is ignored.
+//
+// + YAML is fenced by "---" and closed by "---" or "..."
+// + TOML is fenced by "+++" (Hugo) or "%%%" (Mmark)
+// + JSON is an object starting the first line, its "{" followed by
+//   a quote, whitespace or the end of the line
+//
+// Trailing whitespace on fence lines is ignored. A *FrontMatterError
+// is returned if the front matter isn't closed.
+func SplitFrontMatterWithError(input []byte) (int, []byte, []byte, error) {
+	return splitFrontMatter(normalizeEOL(input))
+}
+
+// splitFrontMatter implements SplitFrontMatterWithError without
+// normalizing line endings, lines may end in "\r\n" and the body is
+// returned as found in input.
+func splitFrontMatter(input []byte) (int, []byte, []byte, error) {
+	input = bytes.TrimPrefix(input, byteOrderMark)
+	firstLine, rest := splitLine(input)
+	fence := string(bytes.TrimRight(firstLine, " \t\r"))
+	configType := ConfigIsUnknown
+	closers := []string{}
+	switch fence {
+	case "---":
+		configType, closers = ConfigIsYAML, []string{"---", "..."}
+	case "+++":
+		configType, closers = ConfigIsTOML, []string{"+++"}
+	case "%%%":
+		configType, closers = ConfigIsTOML, []string{"%%%"}
+	default:
+		if isJSONFence(firstLine) {
+			frontMatter, body, err := splitJSONFrontMatter(input)
+			if err != nil {
+				return ConfigIsUnknown, []byte(""), input, err
+			}
+			return ConfigIsJSON, frontMatter, body, nil
+		}
+		// Handle case of no front matter
+		return ConfigIsUnknown, []byte(""), input, nil
 	}
-	// JSON front matter, most Markdown processors.
-	if bytes.HasPrefix(input, []byte("{\n")) {
-		parts := bytes.SplitN(bytes.TrimPrefix(input, []byte("{\n")), []byte("\n}\n"), 2)
-		src := []byte(fmt.Sprintf("{\n%s\n}\n", parts[0]))
-		return ConfigIsJSON, src, parts[1]
+	lineNo := 1
+	start := len(input) - len(rest)
+	for pos := start; len(rest) > 0; {
+		var line []byte
+		line, rest = splitLine(rest)
+		lineNo++
+		closer := string(bytes.TrimRight(line, " \t\r"))
+		for _, c := range closers {
+			if closer == c {
+				frontMatter := input[start:pos]
+				// NOTE: the newline before the closing fence isn't part of the front matter
+				frontMatter = bytes.TrimSuffix(bytes.TrimSuffix(frontMatter, []byte("\n")), []byte("\r"))
+				return configType, frontMatter, rest, nil
+			}
+		}
+		pos += len(line) + 1
 	}
-	// Handle case of no front matter
-	return ConfigIsUnknown, []byte(""), input
+	return ConfigIsUnknown, []byte(""), input, &FrontMatterError{Fence: fence, Line: 1, EndLine: lineNo, Message: fmt.Sprintf("is never closed by %q", strings.Join(closers, `" or "`))}
+}
+
+// SplitFrontMatter takes a []byte input splits it into front matter
+// source and Markdown source. If either is missing an empty []byte
+// is returned for the missing element. Front matter which can't be
+// split (e.g. an unclosed fence) is treated as part of the Markdown
+// source, use SplitFrontMatterWithError to find out why.
+func SplitFrontMatter(input []byte) (int, []byte, []byte) {
+	configType, frontMatterSrc, src, _ := SplitFrontMatterWithError(input)
+	return configType, frontMatterSrc, src
 }
 
 // ProcessorConfig takes front matter and returns
-// a map[string]interface{} containing configuration
+// a map[string]interface{} containing configuration. See
+// documentConfig for how it is merged with Config.
 func ProcessorConfig(configType int, frontMatterSrc []byte) (map[string]interface{}, error) {
-	//FIXME: Need to merge with .Config and return the merged result.
 	m := map[string]interface{}{}
 	// Do nothing is we have zero front matter to process.
 	if len(frontMatterSrc) == 0 {
@@ -206,7 +365,16 @@ func ProcessorConfig(configType int, frontMatterSrc []byte) (map[string]interfac
 	case ConfigIsJSON:
 		// JSON Front Matter
 		if err := json.Unmarshal(frontMatterSrc, &m); err != nil {
-			return nil, err
+			if syntaxErr, ok := err.(*json.SyntaxError); ok == true {
+				// NOTE: JSON front matter starts the document so its lines are the document's
+				offset := int(syntaxErr.Offset)
+				if offset > len(frontMatterSrc) {
+					offset = len(frontMatterSrc)
+				}
+				line := bytes.Count(frontMatterSrc[0:offset], []byte("\n")) + 1
+				return nil, fmt.Errorf("Can't parse JSON front matter, line %d, %s", line, err)
+			}
+			return nil, fmt.Errorf("Can't parse JSON front matter, %s", err)
 		}
 	default:
 		return nil, fmt.Errorf("unknown front matter format")
@@ -214,6 +382,17 @@ func ProcessorConfig(configType int, frontMatterSrc []byte) (map[string]interfac
 	return m, nil
 }
 
+// documentConfig parses a document's front matter and merges it
+// with DefaultConfig, Config and ConfigOverrides. The result is
+// the configuration used by the markup processors.
+func documentConfig(configType int, frontMatterSrc []byte) (map[string]interface{}, error) {
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return nil, err
+	}
+	return MergeConfig(DefaultConfig, Config, frontMatter, ConfigOverrides), nil
+}
+
 // mmarkExtensions takes a config (map[string]interface{}) and
 // returns the ORed exentions flags for map["mmark"]
 func mmarkExtensions(config map[string]interface{}) parser.Extensions {
@@ -366,9 +545,11 @@ func ConfigMmark(config map[string]interface{}) (parser.Extensions, html.Flags,
 
 // mmarkProcessor runs gomarkdown engine using the Mmark extentions and an HTML renderer setup
 func mmarkProcessor(fName string, input []byte) ([]byte, error) {
-	configType, frontMatterSrc, mmarkSrc := SplitFrontMatter(input)
-	//NOTE: should inspect front matter for Markdown parsing configuration
-	config, err := ProcessorConfig(configType, frontMatterSrc)
+	configType, frontMatterSrc, mmarkSrc, err := SplitFrontMatterWithError(input)
+	if err != nil {
+		return nil, err
+	}
+	config, err := documentConfig(configType, frontMatterSrc)
 	if err != nil {
 		return nil, err
 	}
@@ -566,17 +747,19 @@ func ConfigMarkdown(config map[string]interface{}) (parser.Extensions, html.Flag
 // for gomarkdown, mmark and fountain in the
 // document's frontmatter.  You need to supply a
 // markdown processor as a func to envoke the preferred default.
-func markdownProcessor(input []byte, defaultProcessor func([]byte) ([]byte, error)) ([]byte, error) {
+func markdownProcessor(input []byte, defaultProcessor func([]byte, map[string]interface{}) ([]byte, error)) ([]byte, error) {
 	input = normalizeEOL(input)
-	configType, frontMatterSrc, mdSrc := SplitFrontMatter(input)
-	//NOTE: should inspect front matter for Markdown parsing configuration
-	config, err := ProcessorConfig(configType, frontMatterSrc)
+	configType, frontMatterSrc, mdSrc, err := SplitFrontMatterWithError(input)
+	if err != nil {
+		return nil, err
+	}
+	config, err := documentConfig(configType, frontMatterSrc)
 	if err != nil {
 		return nil, err
 	}
 
 	if thing, ok := config["markup"]; ok == true {
-		markup := thing.(string)
+		markup, _ := thing.(string)
 		switch markup {
 		case "mmark":
 			ext, htmlFlags, err := ConfigMmark(config)
@@ -630,19 +813,12 @@ func markdownProcessor(input []byte, defaultProcessor func([]byte) ([]byte, erro
 			r := html.NewRenderer(opts)
 			return markdown.ToHTML(mdSrc, p, r), nil
 		case "fountain":
-			if err := ConfigFountain(config); err != nil {
-				return nil, err
-			}
-			src, err := fountain.Run(mdSrc)
-			if err != nil {
-				return nil, err
-			}
-			return src, nil
+			return runFountain(config, mdSrc)
 		default:
 			return nil, fmt.Errorf("unknown markup engine")
 		}
 	}
-	return defaultProcessor(mdSrc)
+	return defaultProcessor(mdSrc, config)
 }
 
 // gomarkdownProcessor wraps gomarkdown with overrides for
@@ -650,11 +826,16 @@ func markdownProcessor(input []byte, defaultProcessor func([]byte) ([]byte, erro
 // present and configuration via front matter.
 func gomarkdownProcessor(input []byte) ([]byte, error) {
 	input = normalizeEOL(input)
-	return markdownProcessor(input, func(input []byte) ([]byte, error) {
+	return markdownProcessor(input, func(input []byte, config map[string]interface{}) ([]byte, error) {
 		// Default to gomarkdown markdown processor
-		// with CommonExtensions and CommonHTMLFlags
-		p := parser.NewWithExtensions(parser.CommonExtensions)
-		opts := html.RendererOptions{Flags: html.CommonFlags}
+		// with CommonExtensions and CommonHTMLFlags plus
+		// any settings from config["gomarkdown"]
+		ext, htmlFlags, err := ConfigMarkdown(config)
+		if err != nil {
+			return nil, err
+		}
+		p := parser.NewWithExtensions(parser.CommonExtensions | ext)
+		opts := html.RendererOptions{Flags: html.CommonFlags | htmlFlags}
 		r := html.NewRenderer(opts)
 		return markdown.ToHTML(input, p, r), nil
 	})
@@ -663,7 +844,20 @@ func gomarkdownProcessor(input []byte) ([]byte, error) {
 // ConfigFountain sets the fountain defaults then applies
 // the map[string]interface{} overwriting the defaults
 // returns error necessary.
+//
+// NOTE: the fountain settings are package level variables, they
+// apply to every later call to fountain.Run(). mkpage's own processors
+// use the per-document configuration via runFountain instead so
+// concurrent renders don't share settings.
 func ConfigFountain(config map[string]interface{}) error {
+	fountainMutex.Lock()
+	defer fountainMutex.Unlock()
+	return configFountain(config)
+}
+
+// configFountain applies config to the fountain settings, the
+// caller must hold fountainMutex.
+func configFountain(config map[string]interface{}) error {
 	if thing, ok := config["fountain"]; ok == true {
 		cfg := thing.(map[string]interface{})
 		for k, v := range cfg {
@@ -693,26 +887,42 @@ func ConfigFountain(config map[string]interface{}) error {
 // fountainProcessor wraps fountain.Run() splitting off the front
 // matter if present.
 func fountainProcessor(input []byte) ([]byte, error) {
-	var err error
-
-	configType, frontMatterSrc, fountainSrc := SplitFrontMatter(input)
-	config, err := ProcessorConfig(configType, frontMatterSrc)
+	configType, frontMatterSrc, fountainSrc, err := SplitFrontMatterWithError(input)
 	if err != nil {
 		return nil, err
 	}
-	if err := ConfigFountain(config); err != nil {
+	config, err := documentConfig(configType, frontMatterSrc)
+	if err != nil {
 		return nil, err
 	}
-	src, err := fountain.Run(fountainSrc)
-	if err != nil {
+	return runFountain(config, fountainSrc)
+}
+
+// runFountain renders src with the fountain settings in config.
+// The fountain settings are package level so renders are serialized
+// and the previous settings are restored afterwards.
+func runFountain(config map[string]interface{}, src []byte) ([]byte, error) {
+	fountainMutex.Lock()
+	defer fountainMutex.Unlock()
+	asHTMLPage, inlineCSS, linkCSS, css := fountain.AsHTMLPage, fountain.InlineCSS, fountain.LinkCSS, fountain.CSS
+	defer func() {
+		fountain.AsHTMLPage, fountain.InlineCSS, fountain.LinkCSS, fountain.CSS = asHTMLPage, inlineCSS, linkCSS, css
+	}()
+	if err := configFountain(config); err != nil {
 		return nil, err
 	}
-	return src, nil
+	return fountain.Run(src)
 }
 
 // ResolveData takes a data map and reads in the files and URL sources
 // as needed turning the data into strings to be applied to the template.
 func ResolveData(data map[string]string) (map[string]interface{}, error) {
+	return resolveData(data, nil)
+}
+
+// resolveData implements ResolveData. If deps is not nil each file
+// or URL read is recorded in deps along with a hash of its content.
+func resolveData(data map[string]string, deps map[string]string) (map[string]interface{}, error) {
 	var (
 		out map[string]interface{}
 	)
@@ -768,6 +978,9 @@ func ResolveData(data map[string]string) (map[string]interface{}, error) {
 				if err != nil {
 					return out, err
 				}
+				if deps != nil {
+					deps[val] = hashBytes(buf)
+				}
 				if contentTypes, ok := resp.Header["Content-Type"]; ok == true {
 					switch {
 					case isContentType(contentTypes, "application/json") == true:
@@ -801,25 +1014,28 @@ func ResolveData(data map[string]string) (map[string]interface{}, error) {
 			if err != nil {
 				return out, fmt.Errorf("Can't read (%s) %q, %s", key, val, err)
 			}
+			if deps != nil {
+				deps[val] = hashBytes(buf)
+			}
 			ext := path.Ext(val)
 			switch {
 			case strings.Compare(ext, ".fountain") == 0 ||
 				strings.Compare(ext, ".spmd") == 0:
 				src, err := fountainProcessor(buf)
 				if err != nil {
-					return nil, err
+					return nil, fmt.Errorf("Can't render (%s) %q, %s", key, val, err)
 				}
 				out[key] = fmt.Sprintf("%s", src)
 			case strings.Compare(ext, ".md") == 0:
 				src, err := gomarkdownProcessor(buf)
 				if err != nil {
-					return nil, err
+					return nil, fmt.Errorf("Can't render (%s) %q, %s", key, val, err)
 				}
 				out[key] = fmt.Sprintf("%s", src)
 			case strings.Compare(ext, ".mmark") == 0:
 				src, err := mmarkProcessor(val, buf)
 				if err != nil {
-					return nil, err
+					return nil, fmt.Errorf("Can't render (%s) %q, %s", key, val, err)
 				}
 				out[key] = fmt.Sprintf("%s", src)
 			case strings.Compare(ext, ".json") == 0:
@@ -837,12 +1053,14 @@ func ResolveData(data map[string]string) (map[string]interface{}, error) {
 	return out, nil
 }
 
-// MakePage applies the key/value map to the named template in tmpl and renders to writer and returns an error if something goes wrong
+// MakePage applies the key/value map to the named template in tmpl and renders to writer and returns an error if something goes wrong.
+// If "content" names a document (e.g. content=index.md) a Page describing it is available to the template as .page
 func MakePage(wr io.Writer, templateName string, tmpl *template.Template, keyValues map[string]string) error {
 	data, err := ResolveData(keyValues)
 	if err != nil {
 		return fmt.Errorf("Can't resolve data source %s", err)
 	}
+	addPage(data, keyValues, nil)
 	return tmpl.ExecuteTemplate(wr, templateName, data)
 }
 
@@ -23,8 +23,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 	"text/template"
 )
@@ -290,3 +292,177 @@ This is text **in bold** in text.
 			src1, src2)
 	}
 }
+
+func TestConcurrentRender(t *testing.T) {
+	docs := [][]byte{
+		[]byte(`# Hello World
+
+A *Markdown* document.
+`),
+		[]byte(`{
+    "fountain": { "AsHTMLPage": true }
+}
+
+INT. KITCHEN - DAY
+
+JANE
+Is anyone home?
+`),
+		[]byte(`INT. GARDEN - NIGHT
+
+JOHN
+(whispering)
+Over here.
+`),
+		[]byte(`{
+    "markup": "fountain",
+    "fountain": { "AsHTMLPage": true }
+}
+
+EXT. STREET - DAY
+
+A car drives by.
+`),
+	}
+	render := func(i int) ([]byte, error) {
+		switch i % 4 {
+		case 1, 2:
+			return fountainProcessor(docs[i%4])
+		default:
+			return gomarkdownProcessor(docs[i%4])
+		}
+	}
+
+	// Render each document once to know what to expect
+	expected := [][]byte{}
+	for i := range docs {
+		src, err := render(i)
+		if err != nil {
+			t.Errorf("render %d error %s", i, err)
+			t.FailNow()
+		}
+		expected = append(expected, src)
+	}
+	if bytes.Contains(expected[2], []byte("<html")) == true {
+		t.Errorf("expected AsHTMLPage setting of one document not to leak into the next, got %s", expected[2])
+	}
+
+	// Render the documents concurrently, each should match
+	// its sequential rendering.
+	errs := make(chan error, 100)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			src, err := render(i)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if bytes.Compare(src, expected[i%4]) != 0 {
+				errs <- fmt.Errorf("render %d, expected %q, got %q", i, expected[i%4], src)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	type splitTest struct {
+		src         string
+		configType  int
+		frontMatter string
+		body        string
+	}
+	for i, test := range []splitTest{
+		{"# No front matter\n", ConfigIsUnknown, "", "# No front matter\n"},
+		{"---\ntitle: YAML\n---\n# Body\n", ConfigIsYAML, "title: YAML", "# Body\n"},
+		{"---  \ntitle: YAML\n---\t\n# Body\n", ConfigIsYAML, "title: YAML", "# Body\n"},
+		{"---\ntitle: YAML\n...\n# Body\n", ConfigIsYAML, "title: YAML", "# Body\n"},
+		{"---\r\ntitle: YAML\r\n---\r\n# Body\r\n", ConfigIsYAML, "title: YAML", "# Body\n"},
+		{"\xEF\xBB\xBF+++\ntitle = \"TOML\"\n+++\n# Body\n", ConfigIsTOML, "title = \"TOML\"", "# Body\n"},
+		{"%%%\ntitle = \"Mmark\"\n%%%\n# Body\n", ConfigIsTOML, "title = \"Mmark\"", "# Body\n"},
+		{"---\n---\n# Body\n", ConfigIsYAML, "", "# Body\n"},
+		{"+++\ntitle = \"No body\"\n+++", ConfigIsTOML, "title = \"No body\"", ""},
+		{"{\n    \"title\": \"JSON\"\n}\n# Body\n", ConfigIsJSON, "{\n    \"title\": \"JSON\"\n}", "# Body\n"},
+		{"{\n\"title\": \"JSON } {\", \"gomarkdown\": {\n    \"Footnotes\": true}}  \n# Body\n", ConfigIsJSON, "{\n\"title\": \"JSON } {\", \"gomarkdown\": {\n    \"Footnotes\": true}}", "# Body\n"},
+		{"{  \r\n\"title\": \"Escaped \\\" }\"}\r\n# Body\r\n", ConfigIsJSON, "{  \n\"title\": \"Escaped \\\" }\"}", "# Body\n"},
+		// A body starting with a template action or attribute block isn't JSON
+		{"{{ .title }}\n# Body\n", ConfigIsUnknown, "", "{{ .title }}\n# Body\n"},
+		{"{.class}\n# Body\n", ConfigIsUnknown, "", "{.class}\n# Body\n"},
+		{"{#id}\n# Body\n", ConfigIsUnknown, "", "{#id}\n# Body\n"},
+		// JSON may start on the line of its opening brace
+		{"{\"title\": \"One line\"}\n# Body\n", ConfigIsJSON, "{\"title\": \"One line\"}", "# Body\n"},
+		{"{ \"title\": \"Spaced\",\n  \"draft\": true }\n# Body\n", ConfigIsJSON, "{ \"title\": \"Spaced\",\n  \"draft\": true }", "# Body\n"},
+	} {
+		configType, frontMatter, body, err := SplitFrontMatterWithError([]byte(test.src))
+		if err != nil {
+			t.Errorf("(%d) unexpected error %s", i, err)
+			continue
+		}
+		if configType != test.configType || string(frontMatter) != test.frontMatter || string(body) != test.body {
+			t.Errorf("(%d) expected %d, %q, %q, got %d, %q, %q", i, test.configType, test.frontMatter, test.body, configType, frontMatter, body)
+		}
+	}
+
+	// Unclosed front matter is an error, not a panic
+	for i, src := range []string{
+		"---\ntitle: Never closed\n\n# Body\n",
+		"+++\ntitle = \"Never closed\"\n",
+		"%%%\n",
+		"{\n    \"title\": \"Never closed\"\n\n# Body\n",
+		"{\n    \"title\": \"Inner\",\n    \"gomarkdown\": {\n        \"Footnotes\": true\n\n# Body\n",
+		"{\n    \"title\": \"Never closed\n}\n",
+	} {
+		_, _, body, err := SplitFrontMatterWithError([]byte(src))
+		if err == nil {
+			t.Errorf("(%d) expected an error for %q", i, src)
+			continue
+		}
+		fmErr, ok := err.(*FrontMatterError)
+		if ok == false {
+			t.Errorf("(%d) expected a *FrontMatterError, got %T", i, err)
+			continue
+		}
+		// NOTE: the line of the fence, object or string left open
+		expectedLine := []int{1, 1, 1, 1, 3, 2}[i]
+		if fmErr.Line != expectedLine || fmErr.EndLine < fmErr.Line {
+			t.Errorf("(%d) expected line %d, got %s", i, expectedLine, err)
+		}
+		if string(body) != src {
+			t.Errorf("(%d) expected the input back as the body, got %q", i, body)
+		}
+		if _, _, body := SplitFrontMatter([]byte(src)); string(body) != src {
+			t.Errorf("(%d) expected SplitFrontMatter to return the input as the body, got %q", i, body)
+		}
+	}
+	if _, err := gomarkdownProcessor([]byte("---\ntitle: Never closed\n")); err == nil {
+		t.Errorf("expected processing unclosed front matter to fail")
+	}
+
+	// Malformed JSON reports its line
+	_, err := ProcessorConfig(ConfigIsJSON, []byte("{\n    \"title\": \"Missing comma\"\n    \"draft\": true\n}"))
+	if err == nil || strings.Contains(err.Error(), "line 3") == false {
+		t.Errorf("expected an error on line 3, got %v", err)
+	}
+
+	// Rendering names the document with bad front matter
+	tmpDir, err := ioutil.TempDir("", "mkpage-split")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "unclosed.md")
+	ioutil.WriteFile(fName, []byte("---\ntitle: Never closed\n\n# Body\n"), 0664)
+	tmpl := template.Must(template.New("page").Parse(`{{ .content }}`))
+	err = MakePage(ioutil.Discard, "page", tmpl, map[string]string{"content": fName})
+	if err == nil || strings.Contains(err.Error(), fName) == false {
+		t.Errorf("expected an error naming %s, got %v", fName, err)
+	}
+}
@@ -0,0 +1,245 @@
+//
+// Package mkpage page.go provides the page object available to
+// templates when rendering a document.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// PageKey is the name templates use for the Page of the document
+	// being rendered, e.g. {{ .page.Title }}. It is also available
+	// as "Page" for those used to Hugo.
+	PageKey = "page"
+
+	// WordsPerMinute is used to estimate a page's reading time
+	WordsPerMinute = 200
+)
+
+var (
+	headingExp = regexp.MustCompile(`(?is)<h([1-6])([^>]*)>(.*?)</h[1-6]>`)
+	idExp      = regexp.MustCompile(`(?i)\sid\s*=\s*["']([^"']*)["']`)
+	tagExp     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// Heading describes a heading (h1 to h6) in a page's content.
+type Heading struct {
+	// Level is 1 for h1 through 6 for h6
+	Level int
+	// ID is the heading's id attribute if any, useful for links
+	ID string
+	// Text is the heading without markup
+	Text string
+}
+
+// Page holds page level metadata for the document being rendered.
+// It is available to templates as .page (and .Page) along side
+// the front matter and other key/value pairs.
+type Page struct {
+	// FrontMatter holds the document's parsed front matter
+	FrontMatter map[string]interface{}
+	// Title is the front matter title
+	Title string
+	// Content is the rendered document
+	Content string
+	// Source is the path to the document
+	Source string
+	// Output is the path of the rendered page
+	Output string
+	// RelRoot is the relative path from the page to the site's root,
+	// e.g. "../" for blog/post.html, "" for index.html
+	RelRoot string
+	// WordCount is the number of words in the content
+	WordCount int
+	// ReadingTime is the estimated minutes to read the content
+	ReadingTime int
+	// Headings lists the headings in the content in order
+	Headings []*Heading
+	// Date is the front matter date
+	Date time.Time
+	// Lastmod is the front matter lastmod, or the time the
+	// document was last modified
+	Lastmod time.Time
+	// Draft is true if the front matter sets draft to true
+	Draft bool
+	// PublishDate is the front matter publishDate, the page isn't
+	// published before it
+	PublishDate time.Time
+	// ExpiryDate is the front matter expiryDate, the page isn't
+	// published after it
+	ExpiryDate time.Time
+}
+
+// NewPage creates a Page for the document at source from its front
+// matter and rendered content. Output defaults to source with an
+// .html extension and RelRoot to the path from Output back to the
+// current directory.
+func NewPage(source string, frontMatter map[string]interface{}, content string) *Page {
+	page := new(Page)
+	if frontMatter == nil {
+		frontMatter = map[string]interface{}{}
+	}
+	page.FrontMatter = frontMatter
+	page.Content = content
+	page.Source = source
+	page.Output = strings.TrimSuffix(source, filepath.Ext(source)) + ".html"
+	page.RelRoot = relRoot(page.Output)
+	if s, ok := frontMatter["title"].(string); ok == true {
+		page.Title = s
+	}
+	page.WordCount = len(strings.Fields(html.UnescapeString(tagExp.ReplaceAllString(content, " "))))
+	if page.WordCount > 0 {
+		page.ReadingTime = (page.WordCount + WordsPerMinute - 1) / WordsPerMinute
+	}
+	page.Headings = headings(content)
+	if dt, ok := frontMatterDate(frontMatter["date"]); ok == true {
+		page.Date = dt
+	}
+	if dt, ok := frontMatterDate(frontMatter["lastmod"]); ok == true {
+		page.Lastmod = dt
+	} else if info, err := os.Stat(source); err == nil {
+		page.Lastmod = info.ModTime()
+	}
+	page.Draft = isDraft(frontMatter)
+	page.PublishDate, _ = frontMatterDate(frontMatterKey(frontMatter, "publishDate"))
+	page.ExpiryDate, _ = frontMatterDate(frontMatterKey(frontMatter, "expiryDate"))
+	return page
+}
+
+// isDraft returns true if the front matter sets draft to true.
+func isDraft(frontMatter map[string]interface{}) bool {
+	switch v := frontMatter["draft"].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.ToLower(v) == "true"
+	}
+	return false
+}
+
+// frontMatterKey returns the front matter value for key ignoring
+// case, e.g. "publishDate" also finds "publishdate".
+func frontMatterKey(frontMatter map[string]interface{}, key string) interface{} {
+	if v, ok := frontMatter[key]; ok == true {
+		return v
+	}
+	for k, v := range frontMatter {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// IsPublished returns true if a document with frontMatter should be
+// published at now. Drafts (draft = true) are published only when
+// drafts is true and documents with a publishDate after now only
+// when future is true. Documents whose expiryDate has passed are
+// never published.
+func IsPublished(frontMatter map[string]interface{}, now time.Time, drafts bool, future bool) bool {
+	if drafts == false && isDraft(frontMatter) {
+		return false
+	}
+	if dt, ok := frontMatterDate(frontMatterKey(frontMatter, "publishDate")); ok == true && future == false && dt.After(now) {
+		return false
+	}
+	if dt, ok := frontMatterDate(frontMatterKey(frontMatter, "expiryDate")); ok == true && dt.After(now) == false {
+		return false
+	}
+	return true
+}
+
+// relRoot returns the relative path from a page's directory back
+// to the root of the tree the path is relative to.
+func relRoot(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	if path.IsAbs(p) || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	return strings.Repeat("../", strings.Count(p, "/"))
+}
+
+// headings returns the h1 to h6 elements found in HTML content.
+func headings(content string) []*Heading {
+	l := []*Heading{}
+	for _, m := range headingExp.FindAllStringSubmatch(content, -1) {
+		h := new(Heading)
+		fmt.Sscanf(m[1], "%d", &h.Level)
+		if id := idExp.FindStringSubmatch(m[2]); len(id) > 1 {
+			h.ID = id[1]
+		}
+		h.Text = strings.TrimSpace(html.UnescapeString(tagExp.ReplaceAllString(m[3], "")))
+		l = append(l, h)
+	}
+	return l
+}
+
+// frontMatterDate converts a front matter value (a TOML date or a
+// string understood by NormalizeDate or RFC3339) into a time.Time.
+func frontMatterDate(v interface{}) (time.Time, bool) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, true
+	case string:
+		if dt, err := NormalizeDate(d); err == nil {
+			return dt, true
+		}
+		if dt, err := time.Parse(time.RFC3339, d); err == nil {
+			return dt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// addPage adds a Page to the data resolved for a template when
+// keyValues names a local document as "content". Existing "page"
+// and "Page" values aren't replaced.
+func addPage(data map[string]interface{}, keyValues map[string]string, frontMatter map[string]interface{}) *Page {
+	source, ok := keyValues["content"]
+	if ok == false || IsDocument(source) == false {
+		return nil
+	}
+	if _, err := os.Stat(source); err != nil {
+		return nil
+	}
+	if frontMatter == nil {
+		if src, err := ioutil.ReadFile(source); err == nil {
+			configType, frontMatterSrc, _ := SplitFrontMatter(normalizeEOL(src))
+			frontMatter, _ = ProcessorConfig(configType, frontMatterSrc)
+		}
+	}
+	content, _ := data["content"].(string)
+	page := NewPage(source, frontMatter, content)
+	if _, ok := data[PageKey]; ok == false {
+		data[PageKey] = page
+	}
+	if _, ok := data["Page"]; ok == false {
+		data["Page"] = page
+	}
+	return page
+}
@@ -0,0 +1,155 @@
+//
+// page_test.go test routines for page.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestNewPage(t *testing.T) {
+	frontMatter := map[string]interface{}{
+		"title":   "A Blog Post",
+		"date":    "2020-03-30",
+		"lastmod": "2020-04-01 10:30:00",
+		"draft":   true,
+	}
+	content := `<h1 id="a-blog-post">A Blog Post</h1>
+<p>Just a <em>short</em> post &amp; a list.</p>
+<h2>Section <code>Two</code></h2>
+<p>The end.</p>
+`
+	page := NewPage(path.Join("blog", "post.md"), frontMatter, content)
+	if page.Title != "A Blog Post" {
+		t.Errorf("expected title, got %q", page.Title)
+	}
+	if page.Output != path.Join("blog", "post.html") || page.RelRoot != "../" {
+		t.Errorf("expected blog/post.html and ../, got %q and %q", page.Output, page.RelRoot)
+	}
+	// A Blog Post, Just a short post & a list., Section Two, The end.
+	if page.WordCount != 14 || page.ReadingTime != 1 {
+		t.Errorf("expected 14 words and 1 minute, got %d and %d", page.WordCount, page.ReadingTime)
+	}
+	if len(page.Headings) != 2 {
+		t.Errorf("expected 2 headings, got %+v", page.Headings)
+		t.FailNow()
+	}
+	if h := page.Headings[0]; h.Level != 1 || h.ID != "a-blog-post" || h.Text != "A Blog Post" {
+		t.Errorf("unexpected first heading %+v", h)
+	}
+	if h := page.Headings[1]; h.Level != 2 || h.ID != "" || h.Text != "Section Two" {
+		t.Errorf("unexpected second heading %+v", h)
+	}
+	if page.Date.Equal(time.Date(2020, 3, 30, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected date %s", page.Date)
+	}
+	if page.Lastmod.Equal(time.Date(2020, 4, 1, 10, 30, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected lastmod %s", page.Lastmod)
+	}
+	if page.Draft == false {
+		t.Errorf("expected draft to be true")
+	}
+
+	page = NewPage("index.md", nil, "")
+	if page.RelRoot != "" || page.WordCount != 0 || page.ReadingTime != 0 || page.Draft == true {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestPageInTemplate(t *testing.T) {
+	src := `{{define "page.tmpl"}}{{.page.Title}}|{{.Page.Source}}|{{.page.RelRoot}}|{{.page.Date.Year}}{{end}}`
+	tmpl := template.Must(template.New("page.tmpl").Parse(src))
+	out, err := MakePageString("page.tmpl", tmpl, map[string]string{
+		"content": path.Join("testdata", "site", "blog", "post.md"),
+	})
+	if err != nil {
+		t.Errorf("MakePageString() error %s", err)
+		t.FailNow()
+	}
+	expected := "A Blog Post|" + path.Join("testdata", "site", "blog", "post.md") + "|../../../|1"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// Site builds set the output path and relative root
+	outDir, err := ioutil.TempDir("", "mkpage-page")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(outDir)
+	tmplFName := path.Join(outDir, "page.tmpl")
+	ioutil.WriteFile(tmplFName, []byte(`{{.page.Title}}|{{.page.Output}}|{{.page.RelRoot}}`), 0664)
+	site := new(Site)
+	site.ContentDir = path.Join("testdata", "site")
+	site.OutputDir = path.Join(outDir, "htdocs")
+	site.Templates = []string{tmplFName}
+	if _, err := site.Build(); err != nil {
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	outFName := path.Join(site.OutputDir, "blog", "post.html")
+	buf, _ := ioutil.ReadFile(outFName)
+	expected = "A Blog Post|" + outFName + "|../"
+	if strings.TrimSpace(string(buf)) != expected {
+		t.Errorf("expected %q, got %q", expected, buf)
+	}
+}
+
+func TestIsPublished(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	testData := []struct {
+		frontMatter   map[string]interface{}
+		drafts        bool
+		future        bool
+		expectedValue bool
+	}{
+		{map[string]interface{}{}, false, false, true},
+		{map[string]interface{}{"draft": true}, false, false, false},
+		{map[string]interface{}{"draft": "true"}, true, false, true},
+		{map[string]interface{}{"draft": false}, false, false, true},
+		{map[string]interface{}{"publishDate": "2020-05-31"}, false, false, true},
+		{map[string]interface{}{"publishDate": "2020-06-02"}, false, false, false},
+		{map[string]interface{}{"publishdate": "2020-06-02"}, false, true, true},
+		{map[string]interface{}{"publishDate": time.Date(2020, 6, 1, 13, 0, 0, 0, time.UTC)}, false, false, false},
+		{map[string]interface{}{"expiryDate": "2020-06-02"}, false, false, true},
+		{map[string]interface{}{"expiryDate": "2020-05-31"}, false, false, false},
+		{map[string]interface{}{"expiryDate": "2020-05-31"}, true, true, false},
+		{map[string]interface{}{"draft": true, "publishDate": "2020-06-02"}, true, false, false},
+		{map[string]interface{}{"draft": true, "publishDate": "2020-06-02"}, true, true, true},
+	}
+	for i, test := range testData {
+		if result := IsPublished(test.frontMatter, now, test.drafts, test.future); result != test.expectedValue {
+			t.Errorf("(%d) expected %t for %+v (drafts %t, future %t)", i, test.expectedValue, test.frontMatter, test.drafts, test.future)
+		}
+	}
+
+	page := NewPage("post.md", map[string]interface{}{"publishDate": "2020-05-31", "expirydate": "2020-12-31"}, "")
+	if page.PublishDate.Equal(time.Date(2020, 5, 31, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected publish date %s", page.PublishDate)
+	}
+	if page.ExpiryDate.Equal(time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)) == false {
+		t.Errorf("unexpected expiry date %s", page.ExpiryDate)
+	}
+}
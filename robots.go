@@ -0,0 +1,175 @@
+//
+// Package mkpage robots.go renders a robots.txt from user agent
+// rules and the site's sitemaps, see https://www.robotstxt.org.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RobotsGroup holds the rules of robots.txt for some user agents.
+type RobotsGroup struct {
+	// UserAgents the rules apply to, e.g. "*" or "Googlebot"
+	UserAgents []string
+	// Allow lists paths that may be crawled
+	Allow []string
+	// Disallow lists paths that may not be crawled
+	Disallow []string
+	// CrawlDelay is the seconds between requests, empty for none
+	CrawlDelay string
+}
+
+// Robots describes a site's robots.txt.
+type Robots struct {
+	// Groups of user agent rules
+	Groups []*RobotsGroup
+	// Sitemaps lists the URLs of the site's sitemaps and sitemap
+	// indexes
+	Sitemaps []string
+}
+
+// configList returns the first of keys found in m as a list of
+// strings.
+func configList(m map[string]interface{}, keys ...string) []string {
+	for _, key := range keys {
+		if v := frontMatterKey(m, key); v != nil {
+			return FrontMatterList(v)
+		}
+	}
+	return []string{}
+}
+
+// RobotsGroups returns the user agent groups described by a
+// configuration value, a list of tables with "user_agent", "allow",
+// "disallow" and "crawl_delay". E.g. in TOML
+//
+//     [[robots_rules]]
+//     user_agent = "*"
+//     disallow = [ "/private/", "/tmp/" ]
+//     crawl_delay = 10
+//
+func RobotsGroups(val interface{}) ([]*RobotsGroup, error) {
+	tables := []map[string]interface{}{}
+	switch v := val.(type) {
+	case nil:
+	case map[string]interface{}:
+		tables = append(tables, v)
+	case []map[string]interface{}:
+		tables = v
+	case []interface{}:
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if ok == false {
+				return nil, fmt.Errorf("Can't read robots rule %v, expected a table", item)
+			}
+			tables = append(tables, m)
+		}
+	default:
+		return nil, fmt.Errorf("Can't read robots rules %v, expected a list of tables", val)
+	}
+	groups := []*RobotsGroup{}
+	for _, m := range tables {
+		group := &RobotsGroup{
+			UserAgents: configList(m, "user_agent", "user-agent", "user_agents"),
+			Allow:      configList(m, "allow"),
+			Disallow:   configList(m, "disallow"),
+		}
+		if len(group.UserAgents) == 0 {
+			return nil, fmt.Errorf("Can't read robots rule %v, missing user_agent", m)
+		}
+		for _, key := range []string{"crawl_delay", "crawl-delay"} {
+			if v := frontMatterKey(m, key); v != nil {
+				group.CrawlDelay = fmt.Sprintf("%v", v)
+				break
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// Disallow adds paths to the disallowed paths of every group. A
+// crawler only follows the group naming it, so the paths are added
+// to the groups for named user agents as well as the one for all
+// user agents ("*"), which is added if needed.
+func (robots *Robots) Disallow(paths ...string) {
+	found := false
+	for _, g := range robots.Groups {
+		for _, agent := range g.UserAgents {
+			if agent == "*" {
+				found = true
+			}
+		}
+	}
+	if found == false {
+		robots.Groups = append(robots.Groups, &RobotsGroup{UserAgents: []string{"*"}})
+	}
+	for _, group := range robots.Groups {
+		for _, p := range paths {
+			found := false
+			for _, d := range group.Disallow {
+				if d == p {
+					found = true
+				}
+			}
+			if found == false {
+				group.Disallow = append(group.Disallow, p)
+			}
+		}
+	}
+}
+
+// String renders robots.txt. Without any groups all user agents
+// are allowed everything.
+func (robots *Robots) String() string {
+	var out strings.Builder
+	groups := robots.Groups
+	if len(groups) == 0 {
+		groups = []*RobotsGroup{{UserAgents: []string{"*"}}}
+	}
+	for i, group := range groups {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		for _, agent := range group.UserAgents {
+			fmt.Fprintf(&out, "User-agent: %s\n", agent)
+		}
+		if group.CrawlDelay != "" {
+			fmt.Fprintf(&out, "Crawl-delay: %s\n", group.CrawlDelay)
+		}
+		for _, p := range group.Allow {
+			fmt.Fprintf(&out, "Allow: %s\n", p)
+		}
+		for _, p := range group.Disallow {
+			fmt.Fprintf(&out, "Disallow: %s\n", p)
+		}
+		if len(group.Allow) == 0 && len(group.Disallow) == 0 {
+			// NOTE: an empty Disallow allows everything
+			out.WriteString("Disallow:\n")
+		}
+	}
+	if len(robots.Sitemaps) > 0 {
+		out.WriteString("\n")
+		for _, u := range robots.Sitemaps {
+			fmt.Fprintf(&out, "Sitemap: %s\n", u)
+		}
+	}
+	return out.String()
+}
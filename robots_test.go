@@ -0,0 +1,98 @@
+//
+// robots_test.go test routines for robots.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRobots(t *testing.T) {
+	robots := new(Robots)
+	expected := "User-agent: *\nDisallow:\n"
+	if s := robots.String(); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+
+	rules := []interface{}{
+		map[string]interface{}{
+			"user_agent": "*",
+			"disallow":   []interface{}{"/private/", "/tmp/"},
+			"allow":      "/private/about.html",
+		},
+		map[string]interface{}{
+			"user_agent":  []interface{}{"BadBot", "WorseBot"},
+			"disallow":    "/",
+			"crawl_delay": int64(10),
+		},
+	}
+	groups, err := RobotsGroups(rules)
+	if err != nil {
+		t.Errorf("RobotsGroups() error %s", err)
+		t.FailNow()
+	}
+	robots.Groups = groups
+	robots.Disallow("/drafts/page.html", "/tmp/")
+	robots.Sitemaps = []string{"http://example.edu/sitemap.xml", "http://example.edu/blog/sitemap.xml"}
+	expected = strings.Join([]string{
+		"User-agent: *",
+		"Allow: /private/about.html",
+		"Disallow: /private/",
+		"Disallow: /tmp/",
+		"Disallow: /drafts/page.html",
+		"",
+		"User-agent: BadBot",
+		"User-agent: WorseBot",
+		"Crawl-delay: 10",
+		"Disallow: /",
+		"Disallow: /drafts/page.html",
+		"Disallow: /tmp/",
+		"",
+		"Sitemap: http://example.edu/sitemap.xml",
+		"Sitemap: http://example.edu/blog/sitemap.xml",
+		"",
+	}, "\n")
+	if s := robots.String(); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	// Disallow adds a group for all user agents if needed and
+	// disallows the paths for the named user agents too
+	robots = &Robots{Groups: []*RobotsGroup{{UserAgents: []string{"Googlebot"}, Allow: []string{"/"}}}}
+	robots.Disallow("/secret.html")
+	expected = strings.Join([]string{
+		"User-agent: Googlebot",
+		"Allow: /",
+		"Disallow: /secret.html",
+		"",
+		"User-agent: *",
+		"Disallow: /secret.html",
+		"",
+	}, "\n")
+	if s := robots.String(); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	for _, bad := range []interface{}{"disallow", []interface{}{"*"}, []interface{}{map[string]interface{}{"disallow": "/"}}} {
+		if _, err := RobotsGroups(bad); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+//
+// Package mkpage schema.go validates front matter against a JSON
+// Schema or a simpler field specification.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FieldSpec describes the value expected for a front matter field.
+type FieldSpec struct {
+	// Types lists the acceptable types, "string", "number", "integer",
+	// "boolean" (or "bool"), "array", "object" or "date". Empty means
+	// any type.
+	Types []string
+	// Enum lists the allowed values, empty means any value
+	Enum []interface{}
+	// Format "date" or "date-time" requires a date as read when
+	// building a site, a string NormalizeDate can read, an RFC 3339
+	// timestamp or a TOML date
+	Format string
+}
+
+// Schema describes the front matter expected in documents. It can be
+// read from a subset of JSON Schema (the "required" and "properties"
+// keywords with "type", "enum" and "format") or a simpler field
+// specification using "fields" in place of "properties", e.g.
+//
+//     {
+//         "required": [ "title", "date" ],
+//         "fields": {
+//             "title": { "type": "string" },
+//             "date": { "type": "date" },
+//             "draft": { "type": "boolean" },
+//             "type": { "enum": [ "post", "article", "homepage" ] },
+//             "markup": { "enum": [ "mmark", "gomarkdown", "fountain" ] }
+//         }
+//     }
+//
+type Schema struct {
+	// Required lists the fields every document must have
+	Required []string
+	// Fields maps a field name to its specification
+	Fields map[string]*FieldSpec
+}
+
+// Violation describes a document's front matter failing a Schema.
+type Violation struct {
+	// File is the document's path
+	File string
+	// Field is the front matter field, empty if the problem is
+	// with the front matter as a whole
+	Field string
+	// Message describes the problem
+	Message string
+}
+
+// String returns the violation as "FILE: FIELD, MESSAGE".
+func (v *Violation) String() string {
+	if v.Field == "" {
+		return fmt.Sprintf("%s: %s", v.File, v.Message)
+	}
+	return fmt.Sprintf("%s: %s, %s", v.File, v.Field, v.Message)
+}
+
+// NewSchema creates a Schema from a parsed JSON Schema or
+// field specification.
+func NewSchema(m map[string]interface{}) (*Schema, error) {
+	schema := new(Schema)
+	schema.Fields = map[string]*FieldSpec{}
+	if thing, ok := m["required"]; ok == true {
+		l, ok := thing.([]interface{})
+		if ok == false {
+			return nil, fmt.Errorf("Can't read schema, required should be a list of field names")
+		}
+		for _, item := range l {
+			schema.Required = append(schema.Required, fmt.Sprintf("%v", item))
+		}
+	}
+	fields, ok := m["properties"]
+	if ok == false {
+		fields, ok = m["fields"]
+	}
+	if ok == false {
+		return schema, nil
+	}
+	fieldMap, ok := fields.(map[string]interface{})
+	if ok == false {
+		return nil, fmt.Errorf("Can't read schema, properties should be an object")
+	}
+	for name, thing := range fieldMap {
+		spec := new(FieldSpec)
+		def, ok := thing.(map[string]interface{})
+		if ok == false {
+			return nil, fmt.Errorf("Can't read schema for %q, expected an object", name)
+		}
+		switch t := def["type"].(type) {
+		case string:
+			spec.Types = []string{t}
+		case []interface{}:
+			for _, item := range t {
+				spec.Types = append(spec.Types, fmt.Sprintf("%v", item))
+			}
+		case nil:
+		default:
+			return nil, fmt.Errorf("Can't read schema for %q, type should be a string or list", name)
+		}
+		for _, typeName := range spec.Types {
+			switch typeName {
+			case "string", "number", "integer", "boolean", "bool", "array", "object", "date", "null":
+			default:
+				return nil, fmt.Errorf("Can't read schema for %q, unknown type %q", name, typeName)
+			}
+		}
+		if l, ok := def["enum"].([]interface{}); ok == true {
+			spec.Enum = l
+		}
+		if s, ok := def["format"].(string); ok == true {
+			spec.Format = s
+		}
+		schema.Fields[name] = spec
+	}
+	return schema, nil
+}
+
+// ReadSchema reads a Schema from a JSON, TOML or YAML file. If the
+// file is a site configuration its "frontmatter" table is used. A file
+// without a "frontmatter" table must have "required", "properties" or
+// "fields" so a site configuration isn't mistaken for a schema.
+func ReadSchema(fName string) (*Schema, error) {
+	m, err := ReadConfig(fName)
+	if err != nil {
+		return nil, err
+	}
+	if section, ok := m["frontmatter"].(map[string]interface{}); ok == true {
+		m = section
+	} else {
+		_, hasRequired := m["required"]
+		_, hasProperties := m["properties"]
+		_, hasFields := m["fields"]
+		if hasRequired == false && hasProperties == false && hasFields == false {
+			return nil, fmt.Errorf("Can't read schema from %s, expected a [frontmatter] table or required, properties or fields", fName)
+		}
+	}
+	schema, err := NewSchema(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", fName, err)
+	}
+	return schema, nil
+}
+
+// isDate checks a value is a date as read when building a site,
+// see frontMatterDate.
+func isDate(v interface{}) bool {
+	_, ok := frontMatterDate(v)
+	return ok
+}
+
+// isType checks a value against a schema type name.
+func isType(v interface{}, typeName string) bool {
+	switch typeName {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		switch v.(type) {
+		case int, int64, float64:
+			return true
+		}
+	case "integer":
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == math.Trunc(n)
+		}
+	case "boolean", "bool":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "date":
+		return isDate(v)
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+// Validate checks front matter against the schema returning a
+// Violation for each problem found. fName is used in reporting.
+func (schema *Schema) Validate(fName string, frontMatter map[string]interface{}) []*Violation {
+	violations := []*Violation{}
+	for _, field := range schema.Required {
+		if _, ok := frontMatter[field]; ok == false {
+			violations = append(violations, &Violation{File: fName, Field: field, Message: "is required"})
+		}
+	}
+	names := []string{}
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := schema.Fields[name]
+		val, ok := frontMatter[name]
+		if ok == false {
+			continue
+		}
+		if len(spec.Types) > 0 {
+			matched := false
+			for _, typeName := range spec.Types {
+				if isType(val, typeName) {
+					matched = true
+					break
+				}
+			}
+			if matched == false {
+				violations = append(violations, &Violation{File: fName, Field: name, Message: fmt.Sprintf("expected %s, got %T %v", strings.Join(spec.Types, " or "), val, val)})
+				continue
+			}
+		}
+		if len(spec.Enum) > 0 {
+			matched := false
+			for _, allowed := range spec.Enum {
+				if fmt.Sprintf("%v", allowed) == fmt.Sprintf("%v", val) {
+					matched = true
+					break
+				}
+			}
+			if matched == false {
+				l := []string{}
+				for _, allowed := range spec.Enum {
+					l = append(l, fmt.Sprintf("%v", allowed))
+				}
+				violations = append(violations, &Violation{File: fName, Field: name, Message: fmt.Sprintf("%v is not one of %s", val, strings.Join(l, ", "))})
+			}
+		}
+		if (spec.Format == "date" || spec.Format == "date-time") && isDate(val) == false {
+			violations = append(violations, &Violation{File: fName, Field: name, Message: fmt.Sprintf("%v is not a date, expected format like 2006-01-02 15:04:05 -0700", val)})
+		}
+	}
+	return violations
+}
+
+// ValidateFile reads the front matter of a document and checks
+// it against the schema.
+func (schema *Schema) ValidateFile(fName string) []*Violation {
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return []*Violation{{File: fName, Message: err.Error()}}
+	}
+	configType, frontMatterSrc, _, err := SplitFrontMatterWithError(src)
+	if err != nil {
+		return []*Violation{{File: fName, Message: err.Error()}}
+	}
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return []*Violation{{File: fName, Message: fmt.Sprintf("Can't read front matter, %s", err)}}
+	}
+	if frontMatter == nil {
+		frontMatter = map[string]interface{}{}
+	}
+	return schema.Validate(fName, frontMatter)
+}
+
+// ValidatePath checks a document, or every document found under
+// a directory, against the schema.
+func (schema *Schema) ValidatePath(p string) ([]*Violation, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() == false {
+		return schema.ValidateFile(p), nil
+	}
+	violations := []*Violation{}
+	err = filepath.Walk(p, func(fName string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fName != p && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() == false && IsDocument(fName) {
+			violations = append(violations, schema.ValidateFile(fName)...)
+		}
+		return nil
+	})
+	return violations, err
+}
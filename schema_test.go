@@ -0,0 +1,135 @@
+//
+// schema_test.go test routines for schema.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ReadSchema(path.Join("testdata", "schema.json"))
+	if err != nil {
+		t.Errorf("ReadSchema() error %s", err)
+		t.FailNow()
+	}
+	frontMatter := map[string]interface{}{
+		"title":    "A Post",
+		"date":     "2020-03-30 10:00:00",
+		"draft":    false,
+		"keywords": []interface{}{"go", "markdown"},
+		"type":     "post",
+		"markup":   "mmark",
+	}
+	if violations := schema.Validate("post.md", frontMatter); len(violations) != 0 {
+		t.Errorf("expected no violations, got %+v", violations)
+	}
+	// Dates read when building a site are valid, e.g. RFC 3339
+	frontMatter["date"] = "2020-03-30T10:00:00Z"
+	if violations := schema.Validate("post.md", frontMatter); len(violations) != 0 {
+		t.Errorf("expected an RFC 3339 date to be valid, got %+v", violations)
+	}
+
+	frontMatter = map[string]interface{}{
+		"title":    42.0,
+		"date":     "March 30, 2020",
+		"draft":    "yes",
+		"keywords": "go",
+		"type":     "blog",
+		"markup":   "asciidoc",
+	}
+	violations := schema.Validate("post.md", frontMatter)
+	fields := []string{}
+	for _, v := range violations {
+		if v.File != "post.md" {
+			t.Errorf("expected post.md, got %s", v)
+		}
+		fields = append(fields, v.Field)
+	}
+	expected := "date draft keywords markup title type"
+	if strings.Join(fields, " ") != expected {
+		t.Errorf("expected violations for %s, got %+v", expected, violations)
+	}
+
+	violations = schema.Validate("empty.md", map[string]interface{}{})
+	if len(violations) != 2 || violations[0].String() != "empty.md: title, is required" {
+		t.Errorf("expected title and date to be required, got %+v", violations)
+	}
+}
+
+func TestSchemaValidatePath(t *testing.T) {
+	// A JSON Schema works the same way
+	schema, err := NewSchema(map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"title", "date"},
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{"type": "string"},
+			"date":  map[string]interface{}{"type": "string", "format": "date"},
+		},
+	})
+	if err != nil {
+		t.Errorf("NewSchema() error %s", err)
+		t.FailNow()
+	}
+	violations, err := schema.ValidatePath(path.Join("testdata", "site"))
+	if err != nil {
+		t.Errorf("ValidatePath() error %s", err)
+		t.FailNow()
+	}
+	l := []string{}
+	for _, v := range violations {
+		l = append(l, v.String())
+	}
+	expected := []string{
+		path.Join("testdata", "site", "blog", "post.md") + ": date, is required",
+		path.Join("testdata", "site", "index.md") + ": date, is required",
+	}
+	if strings.Join(l, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(l, "\n"))
+	}
+}
+
+func TestReadSchemaSiteConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-schema")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "mkpage.json")
+
+	// A site configuration without a frontmatter table isn't a schema
+	ioutil.WriteFile(fName, []byte(`{"mkpage": {"templates": "templates"}, "sitemapper": {"url": "http://example.edu"}}`), 0664)
+	if _, err := ReadSchema(fName); err == nil {
+		t.Errorf("expected an error reading a schema from %s", fName)
+	}
+
+	ioutil.WriteFile(fName, []byte(`{"mkpage": {"templates": "templates"}, "frontmatter": {"required": ["title"]}}`), 0664)
+	schema, err := ReadSchema(fName)
+	if err != nil {
+		t.Errorf("ReadSchema() error %s", err)
+		t.FailNow()
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "title" {
+		t.Errorf("expected title to be required, got %+v", schema)
+	}
+}
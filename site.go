@@ -0,0 +1,537 @@
+//
+// Package mkpage site.go renders a content tree into an output tree.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	// Caltech Library packages
+	"github.com/caltechlibrary/tmplfn"
+)
+
+const (
+	// DirTemplateName is the template file looked for in a document's
+	// directory (and its parents up to the content root) when building
+	// a site. It overrides the site's default template.
+	DirTemplateName = "page.tmpl"
+
+	// Build actions reported in BuildResult
+
+	// BuildRendered means a document was rendered to HTML
+	BuildRendered = "rendered"
+	// BuildCopied means a static asset was copied unchanged
+	BuildCopied = "copied"
+	// BuildSkipped means the output was up to date with its inputs
+	BuildSkipped = "skipped"
+	// BuildExcluded means a document was a draft, not yet published
+	// or expired so wasn't rendered
+	BuildExcluded = "excluded"
+	// BuildFailed means a document or asset could not be processed
+	BuildFailed = "failed"
+)
+
+// errUnpublished is returned by renderDocument for documents
+// IsPublished excludes.
+var errUnpublished = fmt.Errorf("unpublished")
+
+// BuildResult describes what happened to one file in a site build.
+type BuildResult struct {
+	Source string
+	Output string
+	Action string
+	Err    error
+}
+
+// String returns a one line summary of the result.
+func (r *BuildResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s, %s", r.Action, r.Source, r.Err)
+	}
+	if r.Output == "" {
+		return fmt.Sprintf("%s %s", r.Action, r.Source)
+	}
+	return fmt.Sprintf("%s %s -> %s", r.Action, r.Source, r.Output)
+}
+
+// Site holds the settings for rendering a content tree
+// into an output tree.
+type Site struct {
+	// ContentDir holds the Markdown, Fountain and static assets
+	ContentDir string
+	// OutputDir is where rendered pages and copied assets are written
+	OutputDir string
+	// Templates is the list of template files used when no directory
+	// or front matter template is found. If empty the default
+	// page template is used.
+	Templates []string
+	// Data holds key/value pairs (see ResolveData) applied
+	// to every page.
+	Data map[string]string
+	// Force renders and copies every file ignoring the manifest
+	// of the previous build.
+	Force bool
+	// Jobs is the number of files rendered in parallel, if less
+	// than one the number of CPUs is used.
+	Jobs int
+	// Drafts renders documents marked draft = true
+	Drafts bool
+	// Future renders documents whose publishDate hasn't arrived
+	Future bool
+
+	// mu guards templates, hashes and the manifest being built
+	mu sync.Mutex
+	// assembled templates keyed by their colon joined filenames
+	templates map[string]*template.Template
+	// manifest records the dependencies of each output
+	manifest *Manifest
+	// hashes caches the current hash of each dependency for this build
+	hashes map[string]string
+	// rendered caches pages rendered by RenderHandler
+	rendered map[string]*renderedPage
+	// templateStamps holds the modification stamp of each assembled
+	// template's files when used by RenderHandler
+	templateStamps map[string]string
+}
+
+// IsDocument returns true if fName has an extension mkpage
+// can render (e.g. .md, .mmark, .fountain, .spmd).
+func IsDocument(fName string) bool {
+	switch strings.ToLower(path.Ext(fName)) {
+	case ".md", ".mmark", ".fountain", ".spmd":
+		return true
+	}
+	return false
+}
+
+// skipSource returns true for files which should neither be rendered
+// nor copied, e.g. dot files, templates and the site configuration.
+func (site *Site) skipSource(p string, info os.FileInfo) bool {
+	rel, err := filepath.Rel(site.ContentDir, p)
+	if err != nil || IsDotPath(rel) {
+		return true
+	}
+	if strings.HasSuffix(p, ".tmpl") {
+		return true
+	}
+	for _, name := range ConfigNames {
+		if rel == name {
+			return true
+		}
+	}
+	// Don't walk our own output if it is inside the content tree
+	if out, err := filepath.Abs(site.OutputDir); err == nil {
+		if abs, err := filepath.Abs(p); err == nil {
+			if abs == out || strings.HasPrefix(abs, out+string(os.PathSeparator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// outputPath maps a source path in ContentDir to its path in OutputDir.
+func (site *Site) outputPath(p string) (string, error) {
+	rel, err := filepath.Rel(site.ContentDir, p)
+	if err != nil {
+		return "", err
+	}
+	if IsDocument(rel) {
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + ".html"
+	}
+	return filepath.Join(site.OutputDir, rel), nil
+}
+
+// templateList picks the templates for a document. Front matter
+// "template" (a string or array of filenames) is used first, then
+// a DirTemplateName found in the document's directory or one of its
+// parents up to ContentDir, then the site's Templates.
+func (site *Site) templateList(p string, frontMatter map[string]interface{}) []string {
+	if thing, ok := frontMatter["template"]; ok == true {
+		switch v := thing.(type) {
+		case string:
+			if v != "" {
+				return strings.Split(v, ":")
+			}
+		case []interface{}:
+			l := []string{}
+			for _, item := range v {
+				l = append(l, fmt.Sprintf("%s", item))
+			}
+			if len(l) > 0 {
+				return l
+			}
+		}
+	}
+	root := filepath.Clean(site.ContentDir)
+	for dName := filepath.Dir(p); ; dName = filepath.Dir(dName) {
+		fName := filepath.Join(dName, DirTemplateName)
+		if _, err := os.Stat(fName); err == nil {
+			return []string{fName}
+		}
+		if dName == root || dName == "." || dName == string(os.PathSeparator) {
+			break
+		}
+	}
+	return site.Templates
+}
+
+// assemble returns the template and the name of the template to
+// execute for a list of template files. Assembled templates are cached.
+func (site *Site) assemble(templateSources []string) (*template.Template, string, error) {
+	key := strings.Join(templateSources, ":")
+	templateName := "page.tmpl"
+	if len(templateSources) > 0 {
+		templateName = path.Base(templateSources[0])
+	}
+	site.mu.Lock()
+	defer site.mu.Unlock()
+	if site.templates == nil {
+		site.templates = map[string]*template.Template{}
+	}
+	if t, ok := site.templates[key]; ok == true {
+		return t, templateName, nil
+	}
+	tmpl := tmplfn.New(tmplfn.AllFuncs())
+	if len(templateSources) > 0 {
+		if err := tmpl.ReadFiles(templateSources...); err != nil {
+			return nil, templateName, err
+		}
+	} else {
+		if err := tmpl.Add(templateName, Defaults["/templates/page.tmpl"]); err != nil {
+			return nil, templateName, err
+		}
+	}
+	t, err := tmpl.Assemble()
+	if err != nil {
+		return nil, templateName, err
+	}
+	site.templates[key] = t
+	return t, templateName, nil
+}
+
+// currentHash returns the hash of a dependency as of this build.
+// Hashes are computed once per build.
+func (site *Site) currentHash(name string) string {
+	site.mu.Lock()
+	h, ok := site.hashes[name]
+	site.mu.Unlock()
+	if ok == true {
+		return h
+	}
+	switch name {
+	case DefaultTemplateDep:
+		h = hashBytes(Defaults["/templates/page.tmpl"])
+	case DataDep:
+		h = hashValue(site.Data)
+	case ConfigDep:
+		h = hashValue(MergeConfig(Config, ConfigOverrides))
+	default:
+		h = hashSource(name)
+	}
+	site.mu.Lock()
+	site.hashes[name] = h
+	site.mu.Unlock()
+	return h
+}
+
+// upToDate checks an output file exists and the inputs recorded
+// in its manifest entry are unchanged.
+func (site *Site) upToDate(outPath string, entry *ManifestEntry, templates []string) bool {
+	if site.Force || entry == nil {
+		return false
+	}
+	if _, err := os.Stat(outPath); err != nil {
+		return false
+	}
+	if strings.Join(entry.Templates, ":") != strings.Join(templates, ":") {
+		return false
+	}
+	for name, h := range entry.Dependencies {
+		if site.currentHash(name) != h {
+			return false
+		}
+	}
+	return true
+}
+
+// manifestKey returns the key used for an output path in the manifest.
+func (site *Site) manifestKey(outPath string) string {
+	if rel, err := filepath.Rel(site.OutputDir, outPath); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return outPath
+}
+
+// renderDocument renders the document at p into outPath unless
+// it is up to date. It returns the document's manifest entry and
+// true if rendering was skipped.
+func (site *Site) renderDocument(p string, outPath string) (*ManifestEntry, bool, error) {
+	src, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, false, err
+	}
+	configType, frontMatterSrc, _, err := SplitFrontMatterWithError(src)
+	if err != nil {
+		return nil, false, err
+	}
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return nil, false, err
+	}
+	if IsPublished(frontMatter, time.Now(), site.Drafts, site.Future) == false {
+		return nil, false, errUnpublished
+	}
+	templateSources := site.templateList(p, frontMatter)
+	key := site.manifestKey(outPath)
+	if prev, ok := site.manifest.Outputs[key]; ok == true && prev.Source == p {
+		if site.upToDate(outPath, prev, templateSources) {
+			return prev, true, nil
+		}
+	}
+
+	entry := &ManifestEntry{
+		Source:       p,
+		Templates:    templateSources,
+		Dependencies: map[string]string{},
+	}
+	entry.Dependencies[DataDep] = site.currentHash(DataDep)
+	entry.Dependencies[ConfigDep] = site.currentHash(ConfigDep)
+	if len(templateSources) == 0 {
+		entry.Dependencies[DefaultTemplateDep] = site.currentHash(DefaultTemplateDep)
+	}
+	for _, fName := range templateSources {
+		entry.Dependencies[fName] = site.currentHash(fName)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0775); err != nil {
+		return nil, false, err
+	}
+	fp, err := os.Create(outPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer fp.Close()
+	if err := site.executeDocument(fp, p, outPath, frontMatter, templateSources, entry.Dependencies); err != nil {
+		return nil, false, err
+	}
+	return entry, false, nil
+}
+
+// executeDocument renders the document at p, to be published as
+// outPath, with templateSources writing the result to wr. Files and URLs read are recorded in deps.
+func (site *Site) executeDocument(wr io.Writer, p string, outPath string, frontMatter map[string]interface{}, templateSources []string, deps map[string]string) error {
+	t, templateName, err := site.assemble(templateSources)
+	if err != nil {
+		return err
+	}
+	keyValues := map[string]string{}
+	for k, v := range site.Data {
+		keyValues[k] = v
+	}
+	keyValues["content"] = p
+	data, err := resolveData(keyValues, deps)
+	if err != nil {
+		return fmt.Errorf("Can't resolve data source %s", err)
+	}
+	// Front matter is available to the template, e.g. {{ .title }}
+	for k, v := range frontMatter {
+		if k != "content" && k != PageKey && k != "Page" {
+			data[k] = v
+		}
+	}
+	// As is the Page, e.g. {{ .page.WordCount }}
+	if page := addPage(data, keyValues, frontMatter); page != nil {
+		page.Output = outPath
+		if rel, err := filepath.Rel(site.ContentDir, p); err == nil {
+			page.RelRoot = relRoot(rel)
+		}
+	}
+	return t.ExecuteTemplate(wr, templateName, data)
+}
+
+// copyAsset copies a static file from p to outPath unchanged unless
+// it is up to date. It returns the asset's manifest entry and true
+// if copying was skipped.
+func (site *Site) copyAsset(p string, outPath string, info os.FileInfo) (*ManifestEntry, bool, error) {
+	key := site.manifestKey(outPath)
+	if prev, ok := site.manifest.Outputs[key]; ok == true && prev.Source == p {
+		if site.upToDate(outPath, prev, nil) {
+			return prev, true, nil
+		}
+	}
+	src, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0775); err != nil {
+		return nil, false, err
+	}
+	if err := ioutil.WriteFile(outPath, src, info.Mode().Perm()); err != nil {
+		return nil, false, err
+	}
+	entry := &ManifestEntry{
+		Source: p,
+		Dependencies: map[string]string{
+			p: hashBytes(src),
+		},
+	}
+	return entry, false, nil
+}
+
+// buildFile renders or copies a single source file returning its
+// result and, unless it failed, its manifest entry.
+func (site *Site) buildFile(p string, info os.FileInfo) (*BuildResult, *ManifestEntry) {
+	result := &BuildResult{Source: p}
+	outPath, err := site.outputPath(p)
+	if err != nil {
+		result.Action, result.Err = BuildFailed, err
+		return result, nil
+	}
+	result.Output = outPath
+	var (
+		entry   *ManifestEntry
+		skipped bool
+	)
+	if IsDocument(p) {
+		result.Action = BuildRendered
+		entry, skipped, err = site.renderDocument(p, outPath)
+	} else {
+		result.Action = BuildCopied
+		entry, skipped, err = site.copyAsset(p, outPath, info)
+	}
+	if err == errUnpublished {
+		// NOTE: remove the page if a previous build published it
+		if prev, ok := site.manifest.Outputs[site.manifestKey(outPath)]; ok == true && prev.Source == p {
+			os.Remove(outPath)
+		}
+		result.Action, result.Output = BuildExcluded, ""
+		return result, nil
+	}
+	if err != nil {
+		result.Action, result.Err = BuildFailed, err
+		return result, nil
+	}
+	if skipped {
+		result.Action = BuildSkipped
+	}
+	return result, entry
+}
+
+// Build walks ContentDir rendering documents (.md, .mmark, .fountain,
+// .spmd) to HTML and copying other files unchanged into OutputDir.
+// A BuildResult is returned for each file processed. Errors for
+// individual files are reported in their BuildResult and the build
+// continues, the returned error summarizes any failures.
+//
+// The inputs of each output are recorded in ManifestName in OutputDir.
+// Unless Force is true, outputs whose inputs are unchanged since
+// the previous build are skipped. Up to Jobs files are processed
+// at the same time. Drafts, documents with a future publishDate and
+// expired documents are excluded unless Drafts or Future are set
+// (see IsPublished).
+func (site *Site) Build() ([]*BuildResult, error) {
+	results := []*BuildResult{}
+	if info, err := os.Stat(site.ContentDir); err != nil {
+		return results, err
+	} else if info.IsDir() == false {
+		return results, fmt.Errorf("%s is not a directory", site.ContentDir)
+	}
+	manifestName := filepath.Join(site.OutputDir, ManifestName)
+	prevManifest, err := ReadManifest(manifestName)
+	if err != nil {
+		// NOTE: an unreadable manifest means we rebuild everything
+		prevManifest = NewManifest()
+	}
+	site.manifest = prevManifest
+	site.hashes = map[string]string{}
+	site.templates = map[string]*template.Template{}
+	manifest := NewManifest()
+
+	// Collect the sources first so they can be processed in parallel
+	// while results keep the walk's order.
+	sources := []string{}
+	infos := []os.FileInfo{}
+	err = Walk(site.ContentDir, func(p string, info os.FileInfo) bool {
+		if info == nil || info.IsDir() {
+			return false
+		}
+		return site.skipSource(p, info) == false
+	}, func(p string, info os.FileInfo) error {
+		sources = append(sources, p)
+		infos = append(infos, info)
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+	results = make([]*BuildResult, len(sources))
+	jobs := site.Jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				result, entry := site.buildFile(sources[j], infos[j])
+				results[j] = result
+				if entry != nil {
+					site.mu.Lock()
+					manifest.Outputs[site.manifestKey(result.Output)] = entry
+					site.mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range sources {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := os.MkdirAll(site.OutputDir, 0775); err != nil {
+		return results, err
+	}
+	if err := manifest.Write(manifestName); err != nil {
+		return results, err
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d files failed", failed, len(results))
+	}
+	return results, nil
+}
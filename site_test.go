@@ -0,0 +1,282 @@
+//
+// site_test.go test routines for site.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestSiteBuild(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "mkpage-site")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(outDir)
+
+	site := new(Site)
+	site.ContentDir = path.Join("testdata", "site")
+	site.OutputDir = outDir
+	site.Data = map[string]string{
+		"csspath": "text:/css/site.css",
+	}
+	results, err := site.Build()
+	if err != nil {
+		for _, result := range results {
+			t.Logf("%s", result)
+		}
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Action]++
+	}
+	if counts[BuildRendered] != 2 || counts[BuildCopied] != 1 {
+		t.Errorf("expected 2 rendered and 1 copied, got %+v", counts)
+	}
+
+	// Front matter is available to the template
+	src, err := ioutil.ReadFile(path.Join(outDir, "blog", "post.html"))
+	if err != nil {
+		t.Errorf("expected blog/post.html, %s", err)
+		t.FailNow()
+	}
+	for _, expected := range []string{"<title>A Blog Post</title>", "Just a short post.", "/css/site.css"} {
+		if strings.Contains(string(src), expected) == false {
+			t.Errorf("expected %q in blog/post.html, got %s", expected, src)
+		}
+	}
+
+	// Static assets are copied unchanged
+	expected, _ := ioutil.ReadFile(path.Join("testdata", "site", "css", "site.css"))
+	src, err = ioutil.ReadFile(path.Join(outDir, "css", "site.css"))
+	if err != nil || bytes.Compare(expected, src) != 0 {
+		t.Errorf("expected css/site.css to be copied, %s", err)
+	}
+
+	// The site configuration isn't published
+	if _, err := os.Stat(path.Join(outDir, "mkpage.json")); os.IsNotExist(err) == false {
+		t.Errorf("expected mkpage.json to be skipped")
+	}
+}
+
+func TestSiteIncrementalBuild(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "mkpage-site")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(outDir)
+	navFName := path.Join(outDir, "nav.txt")
+	if err := ioutil.WriteFile(navFName, []byte("Home"), 0664); err != nil {
+		t.Errorf("Can't write %s, %s", navFName, err)
+		t.FailNow()
+	}
+
+	site := new(Site)
+	site.ContentDir = path.Join("testdata", "site")
+	site.OutputDir = path.Join(outDir, "htdocs")
+	site.Data = map[string]string{
+		"nav": navFName,
+	}
+	countActions := func(results []*BuildResult) map[string]int {
+		counts := map[string]int{}
+		for _, result := range results {
+			counts[result.Action]++
+		}
+		return counts
+	}
+
+	results, err := site.Build()
+	if err != nil {
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	if counts := countActions(results); counts[BuildRendered] != 2 || counts[BuildCopied] != 1 {
+		t.Errorf("expected first build to render 2 and copy 1, got %+v", counts)
+	}
+	manifest, err := ReadManifest(path.Join(site.OutputDir, ManifestName))
+	if err != nil {
+		t.Errorf("ReadManifest() error %s", err)
+		t.FailNow()
+	}
+	entry, ok := manifest.Outputs["index.html"]
+	if ok == false {
+		t.Errorf("expected index.html in manifest, got %+v", manifest.Outputs)
+		t.FailNow()
+	}
+	for _, dep := range []string{navFName, path.Join("testdata", "site", "index.md"), DefaultTemplateDep, DataDep} {
+		if _, ok := entry.Dependencies[dep]; ok == false {
+			t.Errorf("expected dependency %q for index.html, got %+v", dep, entry.Dependencies)
+		}
+	}
+
+	// Nothing changed so everything is skipped
+	results, err = site.Build()
+	if err != nil {
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	if counts := countActions(results); counts[BuildSkipped] != 3 {
+		t.Errorf("expected second build to skip 3, got %+v", counts)
+	}
+
+	// Changing a data file rebuilds the pages that depend on it
+	if err := ioutil.WriteFile(navFName, []byte("Home, Blog"), 0664); err != nil {
+		t.Errorf("Can't write %s, %s", navFName, err)
+		t.FailNow()
+	}
+	results, err = site.Build()
+	if err != nil {
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	if counts := countActions(results); counts[BuildRendered] != 2 || counts[BuildSkipped] != 1 {
+		t.Errorf("expected third build to render 2 and skip 1, got %+v", counts)
+	}
+
+	// Force rebuilds everything
+	site.Force = true
+	results, err = site.Build()
+	if err != nil {
+		t.Errorf("Build() error %s", err)
+		t.FailNow()
+	}
+	if counts := countActions(results); counts[BuildSkipped] != 0 {
+		t.Errorf("expected forced build to skip nothing, got %+v", counts)
+	}
+}
+
+func TestSiteParallelBuild(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "mkpage-site")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(outDir)
+
+	// Build the same content one file at a time and in parallel,
+	// results and outputs should be the same.
+	outputs := []string{}
+	for _, jobs := range []int{1, 4} {
+		site := new(Site)
+		site.ContentDir = path.Join("testdata", "site")
+		site.OutputDir = path.Join(outDir, fmt.Sprintf("jobs-%d", jobs))
+		site.Jobs = jobs
+		results, err := site.Build()
+		if err != nil {
+			t.Errorf("Build() with %d jobs error %s", jobs, err)
+			t.FailNow()
+		}
+		l := []string{}
+		for _, result := range results {
+			src, err := ioutil.ReadFile(result.Output)
+			if err != nil {
+				t.Errorf("expected %s with %d jobs, %s", result.Output, jobs, err)
+				continue
+			}
+			l = append(l, fmt.Sprintf("%s %s\n%s", result.Action, result.Source, src))
+		}
+		outputs = append(outputs, strings.Join(l, "\n"))
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("expected the same output for 1 and 4 jobs, got\n%s\n\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestSiteBuildUnpublished(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-site")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	contentDir := path.Join(tmpDir, "content")
+	outDir := path.Join(tmpDir, "docs")
+	os.MkdirAll(contentDir, 0775)
+	docs := map[string]string{
+		"published.md": "---\ntitle: Published\n---\n\n# Published\n",
+		"draft.md":     "---\ntitle: Draft\ndraft: true\n---\n\n# Draft\n",
+		"future.md":    "---\ntitle: Future\npublishDate: \"2999-01-01\"\n---\n\n# Future\n",
+		"expired.md":   "{\n    \"title\": \"Expired\",\n    \"expiryDate\": \"2000-01-01\"\n}\n\n# Expired\n",
+	}
+	for fName, src := range docs {
+		if err := ioutil.WriteFile(path.Join(contentDir, fName), []byte(src), 0664); err != nil {
+			t.Errorf("Can't write %s, %s", fName, err)
+			t.FailNow()
+		}
+	}
+	build := func(drafts bool, future bool) map[string]string {
+		site := new(Site)
+		site.ContentDir = contentDir
+		site.OutputDir = outDir
+		site.Drafts = drafts
+		site.Future = future
+		results, err := site.Build()
+		if err != nil {
+			t.Errorf("Build() error %s", err)
+			t.FailNow()
+		}
+		actions := map[string]string{}
+		for _, result := range results {
+			actions[path.Base(result.Source)] = result.Action
+		}
+		return actions
+	}
+	exists := func(fName string) bool {
+		_, err := os.Stat(path.Join(outDir, fName))
+		return err == nil
+	}
+
+	actions := build(false, false)
+	if actions["published.md"] != BuildRendered {
+		t.Errorf("expected published.md to be rendered, got %q", actions["published.md"])
+	}
+	for _, fName := range []string{"draft.md", "future.md", "expired.md"} {
+		if actions[fName] != BuildExcluded {
+			t.Errorf("expected %s to be excluded, got %q", fName, actions[fName])
+		}
+	}
+	if exists("published.html") == false || exists("draft.html") || exists("future.html") || exists("expired.html") {
+		t.Errorf("expected only published.html")
+	}
+
+	// Previewing includes drafts and future documents, expired
+	// documents stay excluded.
+	actions = build(true, true)
+	if actions["draft.md"] != BuildRendered || actions["future.md"] != BuildRendered || actions["expired.md"] != BuildExcluded {
+		t.Errorf("unexpected actions previewing %+v", actions)
+	}
+	if exists("draft.html") == false || exists("future.html") == false {
+		t.Errorf("expected draft.html and future.html previewing")
+	}
+
+	// A normal build removes the previewed pages
+	build(false, false)
+	if exists("draft.html") || exists("future.html") || exists("published.html") == false {
+		t.Errorf("expected preview pages to be removed")
+	}
+}
@@ -0,0 +1,615 @@
+//
+// Package mkpage sitemap.go renders sitemaps following
+// https://www.sitemaps.org/protocol.html, splitting large sitemaps
+// into several files referenced by a sitemap index.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SitemapNamespace is the XML namespace of sitemaps
+	SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	// XHTMLNamespace is the XML namespace of the alternate language
+	// links in sitemaps
+	XHTMLNamespace = "http://www.w3.org/1999/xhtml"
+	// ImageNamespace is the XML namespace of image sitemaps
+	ImageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+	// VideoNamespace is the XML namespace of video sitemaps
+	VideoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+	// MaxSitemapImages is the most images listed for a page
+	MaxSitemapImages = 1000
+	// MaxSitemapURLs is the most URLs allowed in one sitemap file
+	MaxSitemapURLs = 50000
+	// MaxSitemapSize is the largest (uncompressed) sitemap file allowed
+	MaxSitemapSize = 50 * 1024 * 1024
+)
+
+// SitemapURL describes a page listed in a sitemap.
+type SitemapURL struct {
+	// Loc is the page's URL
+	Loc string
+	// LastMod is when the page was last modified
+	LastMod time.Time
+	// ChangeFreq is how often the page changes, e.g. daily
+	ChangeFreq string
+	// Priority of the page relative to the site's other pages
+	// from "0.0" to "1.0", empty for the default (0.5)
+	Priority string
+	// Language of the page, e.g. "es", used as its hreflang
+	Language string
+	// TranslationKey is shared by the translations of a page
+	TranslationKey string
+	// Alternates are the translations of the page, see LinkTranslations
+	Alternates []*SitemapAlternate
+	// Images on the page, see ReadSitemapMedia
+	Images []*SitemapImage
+	// Videos on the page, see ReadSitemapMedia
+	Videos []*SitemapVideo
+}
+
+// SitemapImage is an image on a page.
+type SitemapImage struct {
+	// Loc is the image's URL
+	Loc string
+}
+
+// SitemapVideo is a video on a page.
+type SitemapVideo struct {
+	// ThumbnailLoc is the URL of the video's poster image
+	ThumbnailLoc string
+	// Title of the video
+	Title string
+	// Description of the video
+	Description string
+	// ContentLoc is the URL of the video file
+	ContentLoc string
+}
+
+// SitemapAlternate is a language version of a page.
+type SitemapAlternate struct {
+	// HrefLang is the language of the page, e.g. "en" or "x-default"
+	HrefLang string
+	// Href is the page's URL
+	Href string
+}
+
+// Sitemap holds the URLs of a site's sitemap.
+type Sitemap struct {
+	// URLs listed in the sitemap
+	URLs []*SitemapURL
+	// MaxURLs is the most URLs written to a sitemap file, if zero
+	// MaxSitemapURLs is used
+	MaxURLs int
+	// MaxSize is the largest sitemap file written in bytes, if zero
+	// MaxSitemapSize is used
+	MaxSize int
+}
+
+// languageExp matches the language part of a page's name, e.g. "es"
+// in page.es.html
+var languageExp = regexp.MustCompile(`^[a-z][a-z](?:[-_][a-zA-Z][a-zA-Z])?$`)
+
+// SplitLanguage returns the name of a page without its language and
+// the language, e.g. "page.html" and "es" for "page.es.html". The
+// language is empty if the name doesn't include one.
+func SplitLanguage(fName string) (string, string) {
+	ext := filepath.Ext(fName)
+	base := strings.TrimSuffix(fName, ext)
+	lang := filepath.Ext(base)
+	if lang == "" || languageExp.MatchString(lang[1:]) == false {
+		return fName, ""
+	}
+	return strings.TrimSuffix(base, lang) + ext, strings.Replace(lang[1:], "_", "-", 1)
+}
+
+// LinkTranslations sets the Alternates of urls with the same
+// TranslationKey to each other, including themselves, as the
+// sitemaps protocol extension for hreflang expects. A URL without a
+// Language is the "x-default" version. URLs without a TranslationKey
+// or translations are left alone.
+func LinkTranslations(urls []*SitemapURL) {
+	groups := map[string][]*SitemapURL{}
+	for _, u := range urls {
+		if u.TranslationKey != "" {
+			groups[u.TranslationKey] = append(groups[u.TranslationKey], u)
+		}
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		alternates := []*SitemapAlternate{}
+		for _, u := range group {
+			hrefLang := u.Language
+			if hrefLang == "" {
+				hrefLang = "x-default"
+			}
+			alternates = append(alternates, &SitemapAlternate{
+				HrefLang: hrefLang,
+				Href:     u.Loc,
+			})
+		}
+		sort.Slice(alternates, func(i, j int) bool {
+			return alternates[i].HrefLang < alternates[j].HrefLang
+		})
+		for _, u := range group {
+			u.Alternates = alternates
+		}
+	}
+}
+
+var (
+	imgExp       = regexp.MustCompile(`(?is)<img(\s[^>]*)?>`)
+	videoExp     = regexp.MustCompile(`(?is)<video(\s[^>]*)?>(.*?)</video>`)
+	sourceExp    = regexp.MustCompile(`(?is)<source(\s[^>]*)?>`)
+	attrExp      = regexp.MustCompile(`(?is)([a-z][a-z0-9_:-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlTitleExp = regexp.MustCompile(`(?is)<title(?:\s[^>]*)?>(.*?)</title>`)
+	metaExp      = regexp.MustCompile(`(?is)<meta(\s[^>]*)?>`)
+	htmlBaseExp  = regexp.MustCompile(`(?is)<base(\s[^>]*)?>`)
+)
+
+// htmlAttrs returns the attributes of an HTML element's attribute
+// list with lower case names and unescaped values.
+func htmlAttrs(src string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrExp.FindAllStringSubmatch(src, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+// resolveURL returns ref relative to base as an absolute URL, an empty
+// string if ref is empty or a data URL.
+func resolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(strings.ToLower(ref), "data:") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(u).String()
+}
+
+// ReadSitemapMedia returns the images (img elements) and videos
+// (video elements with a poster) of an HTML page. Their locations are
+// resolved against the page's URL, pageURL. A video's title is its
+// title attribute, or the page's title, and its description is the
+// page's meta description, or the title.
+func ReadSitemapMedia(src []byte, pageURL string) ([]*SitemapImage, []*SitemapVideo, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Can't parse page URL %q, %s", pageURL, err)
+	}
+	page := string(src)
+	if m := htmlBaseExp.FindStringSubmatch(page); m != nil {
+		if href := resolveURL(base, htmlAttrs(m[1])["href"]); href != "" {
+			base, _ = url.Parse(href)
+		}
+	}
+	images := []*SitemapImage{}
+	seen := map[string]bool{}
+	for _, m := range imgExp.FindAllStringSubmatch(page, -1) {
+		loc := resolveURL(base, htmlAttrs(m[1])["src"])
+		if loc == "" || seen[loc] || len(images) == MaxSitemapImages {
+			continue
+		}
+		seen[loc] = true
+		images = append(images, &SitemapImage{Loc: loc})
+	}
+
+	videos := []*SitemapVideo{}
+	pageTitle, description := "", ""
+	if m := htmlTitleExp.FindStringSubmatch(page); m != nil {
+		pageTitle = plainText(m[1])
+	}
+	for _, m := range metaExp.FindAllStringSubmatch(page, -1) {
+		attrs := htmlAttrs(m[1])
+		if strings.ToLower(attrs["name"]) == "description" {
+			description = strings.TrimSpace(attrs["content"])
+		}
+	}
+	for _, m := range videoExp.FindAllStringSubmatch(page, -1) {
+		attrs := htmlAttrs(m[1])
+		video := &SitemapVideo{
+			ThumbnailLoc: resolveURL(base, attrs["poster"]),
+			Title:        strings.TrimSpace(attrs["title"]),
+			Description:  description,
+			ContentLoc:   resolveURL(base, attrs["src"]),
+		}
+		if video.ContentLoc == "" {
+			if source := sourceExp.FindStringSubmatch(m[2]); source != nil {
+				video.ContentLoc = resolveURL(base, htmlAttrs(source[1])["src"])
+			}
+		}
+		if video.Title == "" {
+			video.Title = pageTitle
+		}
+		if video.Description == "" {
+			video.Description = video.Title
+		}
+		// NOTE: a video needs a thumbnail, a title and its location
+		if video.ThumbnailLoc == "" || video.Title == "" || video.ContentLoc == "" {
+			continue
+		}
+		videos = append(videos, video)
+	}
+	return images, videos, nil
+}
+
+// frontMatterBool returns true for a front matter value of true
+// or "true".
+func frontMatterBool(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		return strings.ToLower(strings.TrimSpace(val)) == "true"
+	}
+	return false
+}
+
+// NoIndex returns true if a document's front matter asks for its
+// page not to be indexed, i.e. noindex = true.
+func NoIndex(frontMatter map[string]interface{}) bool {
+	return frontMatterBool(frontMatterKey(frontMatter, "noindex"))
+}
+
+// ApplyFrontMatter updates the URL from the front matter of the
+// document the page was rendered from. A "lastmod" replaces LastMod,
+// "language" (or "lang") replaces Language, "translationKey" replaces
+// TranslationKey and a "sitemap" table can set "changefreq" (e.g.
+// weekly) and "priority" (0.0 to 1.0). It returns false if the page should be
+// left out of the sitemap, i.e. sitemap.exclude or noindex is true.
+// Invalid changefreq and priority values are ignored.
+func (u *SitemapURL) ApplyFrontMatter(frontMatter map[string]interface{}) bool {
+	if NoIndex(frontMatter) {
+		return false
+	}
+	if dt, ok := frontMatterDate(frontMatterKey(frontMatter, "lastmod")); ok == true {
+		u.LastMod = dt
+	}
+	for _, key := range []string{"language", "lang"} {
+		if s, ok := frontMatterKey(frontMatter, key).(string); ok == true && strings.TrimSpace(s) != "" {
+			u.Language = strings.TrimSpace(s)
+			break
+		}
+	}
+	if s, ok := frontMatterKey(frontMatter, "translationKey").(string); ok == true && strings.TrimSpace(s) != "" {
+		u.TranslationKey = "translationKey:" + strings.TrimSpace(s)
+	}
+	settings, ok := frontMatter["sitemap"].(map[string]interface{})
+	if ok == false {
+		return true
+	}
+	if frontMatterBool(settings["exclude"]) {
+		return false
+	}
+	if s, ok := settings["changefreq"].(string); ok == true {
+		switch s = strings.ToLower(strings.TrimSpace(s)); s {
+		case "always", "hourly", "daily", "weekly", "monthly", "yearly", "never":
+			u.ChangeFreq = s
+		}
+	}
+	priority := -1.0
+	switch val := settings["priority"].(type) {
+	case float64:
+		priority = val
+	case int64:
+		priority = float64(val)
+	case int:
+		priority = float64(val)
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			priority = f
+		}
+	}
+	if priority >= 0 && priority <= 1 {
+		u.Priority = strconv.FormatFloat(priority, 'f', -1, 64)
+	}
+	return true
+}
+
+// sitemapURL is a url element in a sitemap
+type sitemapURL struct {
+	XMLName    xml.Name `xml:"url"`
+	Loc        string   `xml:"loc"`
+	LastMod    string   `xml:"lastmod,omitempty"`
+	ChangeFreq string   `xml:"changefreq,omitempty"`
+	Priority   string   `xml:"priority,omitempty"`
+	Links      []*sitemapLink
+	Images     []*sitemapImage
+	Videos     []*sitemapVideo
+}
+
+// sitemapImage is an image:image element of a url
+type sitemapImage struct {
+	XMLName xml.Name `xml:"image:image"`
+	Loc     string   `xml:"image:loc"`
+}
+
+// sitemapVideo is a video:video element of a url
+type sitemapVideo struct {
+	XMLName      xml.Name `xml:"video:video"`
+	ThumbnailLoc string   `xml:"video:thumbnail_loc"`
+	Title        string   `xml:"video:title"`
+	Description  string   `xml:"video:description"`
+	ContentLoc   string   `xml:"video:content_loc"`
+}
+
+// sitemapLink is an alternate language link of a url
+type sitemapLink struct {
+	XMLName  xml.Name `xml:"xhtml:link"`
+	Rel      string   `xml:"rel,attr"`
+	HrefLang string   `xml:"hreflang,attr"`
+	Href     string   `xml:"href,attr"`
+}
+
+// SitemapRef refers to a sitemap from a sitemap index.
+type SitemapRef struct {
+	// Loc is the URL of the sitemap
+	Loc string
+	// LastMod is the latest lastmod of the sitemap's URLs
+	LastMod time.Time
+}
+
+// sitemapRef is a sitemap element in a sitemap index
+type sitemapRef struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// sitemapDate formats a lastmod date, an empty string is returned
+// for a zero time.
+func sitemapDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// sitemapFile holds an encoded sitemap and its latest lastmod
+type sitemapFile struct {
+	buf     bytes.Buffer
+	count   int
+	lastMod time.Time
+}
+
+const (
+	sitemapHeader = xml.Header + `<urlset xmlns="` + SitemapNamespace + `" xmlns:xhtml="` + XHTMLNamespace + `" xmlns:image="` + ImageNamespace + `" xmlns:video="` + VideoNamespace + `">` + "\n"
+	sitemapFooter = "</urlset>\n"
+	indexHeader   = xml.Header + `<sitemapindex xmlns="` + SitemapNamespace + `">` + "\n"
+	indexFooter   = "</sitemapindex>\n"
+)
+
+// split encodes the URLs into as many sitemap files as needed to
+// keep each within MaxURLs and MaxSize.
+func (sitemap *Sitemap) split() ([]*sitemapFile, error) {
+	maxURLs, maxSize := sitemap.MaxURLs, sitemap.MaxSize
+	if maxURLs <= 0 {
+		maxURLs = MaxSitemapURLs
+	}
+	if maxSize <= 0 {
+		maxSize = MaxSitemapSize
+	}
+	files := []*sitemapFile{}
+	cur := new(sitemapFile)
+	for _, u := range sitemap.URLs {
+		links := []*sitemapLink{}
+		for _, alt := range u.Alternates {
+			links = append(links, &sitemapLink{
+				Rel:      "alternate",
+				HrefLang: alt.HrefLang,
+				Href:     alt.Href,
+			})
+		}
+		images := []*sitemapImage{}
+		for _, img := range u.Images {
+			images = append(images, &sitemapImage{Loc: img.Loc})
+		}
+		videos := []*sitemapVideo{}
+		for _, video := range u.Videos {
+			videos = append(videos, &sitemapVideo{
+				ThumbnailLoc: video.ThumbnailLoc,
+				Title:        video.Title,
+				Description:  video.Description,
+				ContentLoc:   video.ContentLoc,
+			})
+		}
+		src, err := xml.MarshalIndent(&sitemapURL{
+			Loc:        u.Loc,
+			LastMod:    sitemapDate(u.LastMod),
+			ChangeFreq: u.ChangeFreq,
+			Priority:   u.Priority,
+			Links:      links,
+			Images:     images,
+			Videos:     videos,
+		}, "    ", "    ")
+		if err != nil {
+			return nil, err
+		}
+		src = append(src, '\n')
+		if len(sitemapHeader)+len(src)+len(sitemapFooter) > maxSize {
+			return nil, fmt.Errorf("Can't fit %s in a sitemap of %d bytes", u.Loc, maxSize)
+		}
+		if cur.count == maxURLs || len(sitemapHeader)+cur.buf.Len()+len(src)+len(sitemapFooter) > maxSize {
+			files = append(files, cur)
+			cur = new(sitemapFile)
+		}
+		cur.buf.Write(src)
+		cur.count++
+		if u.LastMod.After(cur.lastMod) {
+			cur.lastMod = u.LastMod
+		}
+	}
+	return append(files, cur), nil
+}
+
+// Write saves the sitemap as fName. If the URLs don't fit in one
+// sitemap file (see MaxURLs and MaxSize) they are written to numbered
+// files next to fName (e.g. sitemap-1.xml, sitemap-2.xml for
+// sitemap.xml) and fName becomes a sitemap index referencing them.
+// baseURL is the URL of fName's directory used in the index. Numbered
+// files left from a previous, larger, sitemap are removed. The names
+// of the files written are returned.
+func (sitemap *Sitemap) Write(fName string, baseURL string) ([]string, error) {
+	written, _, err := sitemap.write(fName, baseURL)
+	return written, err
+}
+
+// write saves the sitemap as Write does also returning references
+// to the files holding URLs (i.e. not the index).
+func (sitemap *Sitemap) write(fName string, baseURL string) ([]string, []*SitemapRef, error) {
+	files, err := sitemap.split()
+	if err != nil {
+		return nil, nil, err
+	}
+	ext := filepath.Ext(fName)
+	base := strings.TrimSuffix(fName, ext)
+	written := []string{}
+	refs := []*SitemapRef{}
+	if len(files) == 1 {
+		src := sitemapHeader + files[0].buf.String() + sitemapFooter
+		if err := ioutil.WriteFile(fName, []byte(src), 0664); err != nil {
+			return written, nil, err
+		}
+		written = append(written, fName)
+		refs = append(refs, &SitemapRef{
+			Loc:     strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(fName),
+			LastMod: files[0].lastMod,
+		})
+	} else {
+		for i, f := range files {
+			name := fmt.Sprintf("%s-%d%s", base, i+1, ext)
+			src := sitemapHeader + f.buf.String() + sitemapFooter
+			if err := ioutil.WriteFile(name, []byte(src), 0664); err != nil {
+				return written, nil, err
+			}
+			written = append(written, name)
+			refs = append(refs, &SitemapRef{
+				Loc:     strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(name),
+				LastMod: f.lastMod,
+			})
+		}
+		if err := WriteSitemapIndex(fName, refs); err != nil {
+			return written, nil, err
+		}
+		written = append(written, fName)
+	}
+	// Remove numbered sitemaps no longer referenced
+	next := len(files) + 1
+	if len(files) == 1 {
+		next = 1
+	}
+	for i := next; ; i++ {
+		if err := os.Remove(fmt.Sprintf("%s-%d%s", base, i, ext)); err != nil {
+			break
+		}
+	}
+	return written, refs, nil
+}
+
+// WriteSitemapIndex saves a sitemap index referencing refs as fName.
+func WriteSitemapIndex(fName string, refs []*SitemapRef) error {
+	var index bytes.Buffer
+	index.WriteString(indexHeader)
+	for _, r := range refs {
+		ref, err := xml.MarshalIndent(&sitemapRef{
+			Loc:     r.Loc,
+			LastMod: sitemapDate(r.LastMod),
+		}, "    ", "    ")
+		if err != nil {
+			return err
+		}
+		index.Write(ref)
+		index.WriteString("\n")
+	}
+	index.WriteString(indexFooter)
+	return ioutil.WriteFile(fName, index.Bytes(), 0664)
+}
+
+// SitemapSection is the sitemap of part of a site (e.g. a blog)
+// saved in its own file.
+type SitemapSection struct {
+	Sitemap
+	// FName is the sitemap file of the section
+	FName string
+	// BaseURL is the URL of FName's directory
+	BaseURL string
+}
+
+// SitemapDirURL returns the URL of the directory holding fName where
+// siteURL is the URL of the document root, docRoot. Files outside
+// docRoot are treated as being in it.
+func SitemapDirURL(siteURL *url.URL, docRoot string, fName string) string {
+	rel, err := filepath.Rel(docRoot, filepath.Dir(fName))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = ""
+	}
+	u := *siteURL
+	u.RawPath = ""
+	u.Path = "/" + strings.Trim(path.Join(u.Path, filepath.ToSlash(rel)), "/")
+	return u.String()
+}
+
+// WriteSitemapSections saves the sitemap of each section, see Write,
+// and a sitemap index, indexFName, referencing the sitemaps of all
+// of them. Sections without URLs are skipped, their sitemaps are
+// neither written nor listed in the index. The directory of a
+// section's sitemap is created if needed. The names of the files
+// written are returned.
+func WriteSitemapSections(indexFName string, sections []*SitemapSection) ([]string, error) {
+	written := []string{}
+	refs := []*SitemapRef{}
+	for _, section := range sections {
+		if len(section.URLs) == 0 {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(section.FName), 0775); err != nil {
+			return written, fmt.Errorf("Can't create %s, %s", section.FName, err)
+		}
+		names, sectionRefs, err := section.write(section.FName, section.BaseURL)
+		written = append(written, names...)
+		if err != nil {
+			return written, fmt.Errorf("Can't create %s, %s", section.FName, err)
+		}
+		refs = append(refs, sectionRefs...)
+	}
+	if err := WriteSitemapIndex(indexFName, refs); err != nil {
+		return written, fmt.Errorf("Can't create %s, %s", indexFName, err)
+	}
+	return append(written, indexFName), nil
+}
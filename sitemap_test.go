@@ -0,0 +1,435 @@
+//
+// sitemap_test.go test routines for sitemap.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemapWrite(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-sitemap")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "sitemap.xml")
+
+	sitemap := new(Sitemap)
+	for i := 0; i < 5; i++ {
+		sitemap.URLs = append(sitemap.URLs, &SitemapURL{
+			Loc:        fmt.Sprintf("http://example.edu/page-%d.html?a=1&b=2", i),
+			LastMod:    time.Date(2020, 6, i+1, 12, 0, 0, 0, time.UTC),
+			ChangeFreq: "daily",
+		})
+	}
+	urlset := struct {
+		XMLName xml.Name `xml:"urlset"`
+		URLs    []struct {
+			Loc        string `xml:"loc"`
+			LastMod    string `xml:"lastmod"`
+			ChangeFreq string `xml:"changefreq"`
+		} `xml:"url"`
+	}{}
+
+	// Everything fits in one sitemap
+	written, err := sitemap.Write(fName, "http://example.edu/")
+	if err != nil || len(written) != 1 {
+		t.Errorf("expected one sitemap, got %+v, %v", written, err)
+		t.FailNow()
+	}
+	src, _ := ioutil.ReadFile(fName)
+	if err := xml.Unmarshal(src, &urlset); err != nil || len(urlset.URLs) != 5 {
+		t.Errorf("expected a urlset with 5 urls, %v\n%s", err, src)
+		t.FailNow()
+	}
+	if u := urlset.URLs[0]; u.Loc != "http://example.edu/page-0.html?a=1&b=2" || u.LastMod != "2020-06-01" || u.ChangeFreq != "daily" {
+		t.Errorf("unexpected url %+v", u)
+	}
+
+	// Split by the number of URLs, 2 + 2 + 1
+	sitemap.MaxURLs = 2
+	written, err = sitemap.Write(fName, "http://example.edu/")
+	if err != nil || len(written) != 4 {
+		t.Errorf("expected three sitemaps and an index, got %+v, %v", written, err)
+		t.FailNow()
+	}
+	index := struct {
+		XMLName  xml.Name `xml:"sitemapindex"`
+		Sitemaps []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"sitemap"`
+	}{}
+	src, _ = ioutil.ReadFile(fName)
+	if err := xml.Unmarshal(src, &index); err != nil || len(index.Sitemaps) != 3 {
+		t.Errorf("expected an index of 3 sitemaps, %v\n%s", err, src)
+		t.FailNow()
+	}
+	for i, expected := range []string{"2020-06-02", "2020-06-04", "2020-06-05"} {
+		ref := index.Sitemaps[i]
+		if ref.Loc != fmt.Sprintf("http://example.edu/sitemap-%d.xml", i+1) || ref.LastMod != expected {
+			t.Errorf("unexpected sitemap reference %+v", ref)
+		}
+	}
+	src, _ = ioutil.ReadFile(path.Join(tmpDir, "sitemap-3.xml"))
+	urlset.URLs = nil
+	if err := xml.Unmarshal(src, &urlset); err != nil || len(urlset.URLs) != 1 {
+		t.Errorf("expected sitemap-3.xml to hold one url, %v\n%s", err, src)
+	}
+
+	// Split by size, each url is less than 200 bytes
+	sitemap.MaxURLs = 0
+	sitemap.MaxSize = len(sitemapHeader) + len(sitemapFooter) + 400
+	written, err = sitemap.Write(fName, "http://example.edu")
+	if err != nil || len(written) != 4 {
+		t.Errorf("expected three sitemaps and an index, got %+v, %v", written, err)
+	}
+	for _, name := range written {
+		if info, err := os.Stat(name); err != nil || info.Size() > int64(sitemap.MaxSize) {
+			t.Errorf("expected %s within %d bytes", name, sitemap.MaxSize)
+		}
+	}
+
+	// Numbered sitemaps are removed once they're not needed
+	sitemap.MaxSize = 0
+	if _, err := sitemap.Write(fName, "http://example.edu"); err != nil {
+		t.Errorf("Write() error %s", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := os.Stat(path.Join(tmpDir, fmt.Sprintf("sitemap-%d.xml", i))); err == nil {
+			t.Errorf("expected sitemap-%d.xml to be removed", i)
+		}
+	}
+	src, _ = ioutil.ReadFile(fName)
+	if strings.Contains(string(src), "<urlset") == false {
+		t.Errorf("expected a urlset\n%s", src)
+	}
+}
+
+func TestSitemapURLApplyFrontMatter(t *testing.T) {
+	modTime := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	newURL := func() *SitemapURL {
+		return &SitemapURL{
+			Loc:        "http://example.edu/index.html",
+			LastMod:    modTime,
+			ChangeFreq: "daily",
+		}
+	}
+
+	// No sitemap settings leaves the URL as it was
+	u := newURL()
+	if u.ApplyFrontMatter(map[string]interface{}{"title": "Hello"}) == false {
+		t.Errorf("expected page to be included")
+	}
+	if u.LastMod != modTime || u.ChangeFreq != "daily" || u.Priority != "" {
+		t.Errorf("unexpected url %+v", u)
+	}
+
+	u = newURL()
+	ok := u.ApplyFrontMatter(map[string]interface{}{
+		"lastmod": "2020-07-04",
+		"sitemap": map[string]interface{}{
+			"changefreq": "Weekly",
+			"priority":   0.8,
+		},
+	})
+	if ok == false {
+		t.Errorf("expected page to be included")
+	}
+	if u.LastMod.Format("2006-01-02") != "2020-07-04" || u.ChangeFreq != "weekly" || u.Priority != "0.8" {
+		t.Errorf("unexpected url %+v", u)
+	}
+
+	// Invalid values are ignored
+	u = newURL()
+	u.ApplyFrontMatter(map[string]interface{}{
+		"sitemap": map[string]interface{}{
+			"changefreq": "fortnightly",
+			"priority":   "2",
+		},
+	})
+	if u.ChangeFreq != "daily" || u.Priority != "" {
+		t.Errorf("unexpected url %+v", u)
+	}
+
+	for _, frontMatter := range []map[string]interface{}{
+		{"noindex": true},
+		{"sitemap": map[string]interface{}{"exclude": true}},
+		{"sitemap": map[string]interface{}{"exclude": "true"}},
+	} {
+		if newURL().ApplyFrontMatter(frontMatter) {
+			t.Errorf("expected %+v to exclude the page", frontMatter)
+		}
+	}
+
+	// Priority is written to the sitemap
+	tmpDir, err := ioutil.TempDir("", "mkpage-sitemap")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "sitemap.xml")
+	u = newURL()
+	u.Priority = "1"
+	sitemap := &Sitemap{URLs: []*SitemapURL{u}}
+	if _, err := sitemap.Write(fName, "http://example.edu"); err != nil {
+		t.Errorf("Write() error %s", err)
+		t.FailNow()
+	}
+	src, _ := ioutil.ReadFile(fName)
+	if strings.Contains(string(src), "<priority>1</priority>") == false {
+		t.Errorf("expected a priority\n%s", src)
+	}
+}
+
+func TestWriteSitemapSections(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "mkpage-sitemap")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+
+	root := &SitemapSection{
+		FName:   path.Join(tmpDir, "sitemap.xml"),
+		BaseURL: "http://example.edu",
+	}
+	root.URLs = []*SitemapURL{
+		{Loc: "http://example.edu/index.html", LastMod: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	blog := &SitemapSection{
+		FName:   path.Join(tmpDir, "blog", "sitemap.xml"),
+		BaseURL: "http://example.edu/blog/",
+	}
+	blog.MaxURLs = 1
+	for i := 1; i <= 2; i++ {
+		blog.URLs = append(blog.URLs, &SitemapURL{
+			Loc:     fmt.Sprintf("http://example.edu/blog/post-%d.html", i),
+			LastMod: time.Date(2020, 7, i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	empty := &SitemapSection{
+		FName:   path.Join(tmpDir, "news", "sitemap.xml"),
+		BaseURL: "http://example.edu",
+	}
+	indexFName := path.Join(tmpDir, "sitemap-index.xml")
+	written, err := WriteSitemapSections(indexFName, []*SitemapSection{root, blog, empty})
+	if err != nil {
+		t.Errorf("WriteSitemapSections() error %s", err)
+		t.FailNow()
+	}
+	// sitemap.xml, blog/sitemap-1.xml, blog/sitemap-2.xml, blog/sitemap.xml
+	// and the index, the empty news section isn't written
+	if len(written) != 5 || written[len(written)-1] != indexFName {
+		t.Errorf("unexpected files written %+v", written)
+	}
+	if _, err := os.Stat(empty.FName); os.IsNotExist(err) == false {
+		t.Errorf("expected %s not to be written, %v", empty.FName, err)
+	}
+
+	index := struct {
+		XMLName  xml.Name `xml:"sitemapindex"`
+		Sitemaps []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"sitemap"`
+	}{}
+	src, _ := ioutil.ReadFile(indexFName)
+	if err := xml.Unmarshal(src, &index); err != nil || len(index.Sitemaps) != 3 {
+		t.Errorf("expected an index of 3 sitemaps, %v\n%s", err, src)
+		t.FailNow()
+	}
+	for i, expected := range []string{
+		"http://example.edu/sitemap.xml",
+		"http://example.edu/blog/sitemap-1.xml",
+		"http://example.edu/blog/sitemap-2.xml",
+	} {
+		if index.Sitemaps[i].Loc != expected {
+			t.Errorf("expected %q, got %+v", expected, index.Sitemaps[i])
+		}
+	}
+	if index.Sitemaps[2].LastMod != "2020-07-02" {
+		t.Errorf("unexpected lastmod %+v", index.Sitemaps[2])
+	}
+}
+
+func TestSitemapDirURL(t *testing.T) {
+	for _, base := range []string{"https://example.org", "https://example.org/"} {
+		site, _ := url.Parse(base)
+		for fName, expected := range map[string]string{
+			"htdocs/sitemap.xml":        "https://example.org/",
+			"htdocs/blog/sitemap.xml":   "https://example.org/blog",
+			"htdocs/blog/a b/index.xml": "https://example.org/blog/a%20b",
+			"sitemap.xml":               "https://example.org/",
+		} {
+			result := SitemapDirURL(site, "htdocs", fName)
+			if result != expected {
+				t.Errorf("SitemapDirURL(%q, %q) expected %q, got %q", base, fName, expected, result)
+			}
+		}
+	}
+	site, _ := url.Parse("https://example.org/sub")
+	if result := SitemapDirURL(site, "htdocs", "htdocs/blog/sitemap.xml"); result != "https://example.org/sub/blog" {
+		t.Errorf("expected https://example.org/sub/blog, got %q", result)
+	}
+}
+
+func TestSitemapTranslations(t *testing.T) {
+	for fName, expected := range map[string][2]string{
+		"/page.html":            {"/page.html", ""},
+		"/page.es.html":         {"/page.html", "es"},
+		"/blog/post.pt_BR.html": {"/blog/post.html", "pt-BR"},
+		"/js/app.min.html":      {"/js/app.min.html", ""},
+	} {
+		name, lang := SplitLanguage(fName)
+		if name != expected[0] || lang != expected[1] {
+			t.Errorf("SplitLanguage(%q) expected %q, %q, got %q, %q", fName, expected[0], expected[1], name, lang)
+		}
+	}
+
+	en := &SitemapURL{Loc: "http://example.edu/page.html", Language: "en", TranslationKey: "/page.html"}
+	es := &SitemapURL{Loc: "http://example.edu/page.es.html", Language: "es", TranslationKey: "/page.html"}
+	other := &SitemapURL{Loc: "http://example.edu/other.html", TranslationKey: "/other.html"}
+	fr := &SitemapURL{Loc: "http://example.edu/autre.html", TranslationKey: "/autre.html"}
+	fr.ApplyFrontMatter(map[string]interface{}{"lang": "fr", "translationKey": "other"})
+	other.ApplyFrontMatter(map[string]interface{}{"translationKey": "other"})
+	LinkTranslations([]*SitemapURL{en, es, other, fr})
+
+	if len(en.Alternates) != 2 || en.Alternates[0].HrefLang != "en" || en.Alternates[1].Href != es.Loc {
+		t.Errorf("unexpected alternates %+v", en.Alternates)
+	}
+	if len(es.Alternates) != 2 {
+		t.Errorf("expected es to have 2 alternates, %+v", es.Alternates)
+	}
+	if len(other.Alternates) != 2 || other.Alternates[0].HrefLang != "fr" || other.Alternates[1].HrefLang != "x-default" {
+		t.Errorf("unexpected alternates %+v", other.Alternates)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "mkpage-sitemap")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "sitemap.xml")
+	sitemap := &Sitemap{URLs: []*SitemapURL{en, es}}
+	if _, err := sitemap.Write(fName, "http://example.edu"); err != nil {
+		t.Errorf("Write() error %s", err)
+		t.FailNow()
+	}
+	src, _ := ioutil.ReadFile(fName)
+	for _, expected := range []string{
+		`xmlns:xhtml="http://www.w3.org/1999/xhtml"`,
+		`<xhtml:link rel="alternate" hreflang="es" href="http://example.edu/page.es.html"></xhtml:link>`,
+	} {
+		if strings.Contains(string(src), expected) == false {
+			t.Errorf("expected %s\n%s", expected, src)
+		}
+	}
+}
+
+func TestReadSitemapMedia(t *testing.T) {
+	src := []byte(`<!DOCTYPE html>
+<html>
+<head>
+<title>Collection &amp; Items</title>
+<meta name="description" content="Photographs from the archive">
+</head>
+<body>
+<img src="images/one.jpg" alt="One">
+<IMG SRC='/images/two.jpg'>
+<img src="images/one.jpg">
+<img src="http://cdn.example.org/three.png">
+<img src="data:image/png;base64,AAAA">
+<video poster="poster.jpg" title="Lecture">
+  <source src="media/lecture.mp4" type="video/mp4">
+</video>
+<video src="media/clip.mp4" poster="clip.jpg"></video>
+<video src="media/no-poster.mp4"></video>
+</body>
+</html>
+`)
+	images, videos, err := ReadSitemapMedia(src, "http://example.edu/collections/index.html")
+	if err != nil {
+		t.Errorf("ReadSitemapMedia() error %s", err)
+		t.FailNow()
+	}
+	expected := []string{
+		"http://example.edu/collections/images/one.jpg",
+		"http://example.edu/images/two.jpg",
+		"http://cdn.example.org/three.png",
+	}
+	if len(images) != len(expected) {
+		t.Errorf("expected %d images, got %+v", len(expected), images)
+		t.FailNow()
+	}
+	for i, loc := range expected {
+		if images[i].Loc != loc {
+			t.Errorf("expected image %q, got %q", loc, images[i].Loc)
+		}
+	}
+	if len(videos) != 2 {
+		t.Errorf("expected 2 videos, got %+v", videos)
+		t.FailNow()
+	}
+	if v := videos[0]; v.Title != "Lecture" || v.Description != "Photographs from the archive" ||
+		v.ThumbnailLoc != "http://example.edu/collections/poster.jpg" || v.ContentLoc != "http://example.edu/collections/media/lecture.mp4" {
+		t.Errorf("unexpected video %+v", v)
+	}
+	if v := videos[1]; v.Title != "Collection & Items" || v.ContentLoc != "http://example.edu/collections/media/clip.mp4" {
+		t.Errorf("unexpected video %+v", v)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "mkpage-sitemap")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(tmpDir)
+	fName := path.Join(tmpDir, "sitemap.xml")
+	sitemap := &Sitemap{URLs: []*SitemapURL{
+		{Loc: "http://example.edu/collections/index.html", Images: images, Videos: videos},
+	}}
+	if _, err := sitemap.Write(fName, "http://example.edu"); err != nil {
+		t.Errorf("Write() error %s", err)
+		t.FailNow()
+	}
+	out, _ := ioutil.ReadFile(fName)
+	for _, s := range []string{
+		`xmlns:image="http://www.google.com/schemas/sitemap-image/1.1"`,
+		`xmlns:video="http://www.google.com/schemas/sitemap-video/1.1"`,
+		`<image:loc>http://example.edu/images/two.jpg</image:loc>`,
+		`<video:title>Collection &amp; Items</video:title>`,
+		`<video:thumbnail_loc>http://example.edu/collections/clip.jpg</video:thumbnail_loc>`,
+	} {
+		if strings.Contains(string(out), s) == false {
+			t.Errorf("expected %s\n%s", s, out)
+		}
+	}
+}
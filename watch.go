@@ -0,0 +1,182 @@
+//
+// Package mkpage watch.go provides polling based change detection
+// used by mkpage and mkslides to re-render when sources change.
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher polls files and directories for changes. Polling works
+// everywhere without filesystem notification support. Directories
+// are watched recursively, dot files and directories are ignored.
+type Watcher struct {
+	// Interval is the time between polls
+	Interval time.Duration
+	// Exclude lists files and directories which aren't watched,
+	// e.g. an output directory inside the content directory.
+	Exclude []string
+
+	// names holds the files and directories being watched
+	names []string
+	// stamps holds the modification time and size last seen
+	// for each file
+	stamps map[string]string
+}
+
+// NewWatcher creates a Watcher polling names every interval. The
+// current state of names is recorded so only later changes are
+// reported.
+func NewWatcher(interval time.Duration, names ...string) *Watcher {
+	w := new(Watcher)
+	w.Interval = interval
+	w.Add(names...)
+	return w
+}
+
+// Add watches more files or directories. Empty names and URLs are
+// ignored.
+func (w *Watcher) Add(names ...string) {
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+			continue
+		}
+		found := false
+		for _, cur := range w.names {
+			if cur == name {
+				found = true
+				break
+			}
+		}
+		if found == false {
+			w.names = append(w.names, name)
+		}
+	}
+	w.stamps = w.scan()
+}
+
+// scan records the modification time and size of each watched file.
+func (w *Watcher) scan() map[string]string {
+	stamps := map[string]string{}
+	stamp := func(info os.FileInfo) string {
+		return fmt.Sprintf("%s %d", info.ModTime().Format(time.RFC3339Nano), info.Size())
+	}
+	for _, name := range w.names {
+		info, err := os.Stat(name)
+		if err != nil {
+			// NOTE: a missing file is recorded so its creation is noticed
+			stamps[name] = ""
+			continue
+		}
+		if info.IsDir() == false {
+			stamps[name] = stamp(info)
+			continue
+		}
+		filepath.Walk(name, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info == nil {
+				return nil
+			}
+			if p != name && (strings.HasPrefix(info.Name(), ".") || w.excluded(p)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() == false {
+				stamps[p] = stamp(info)
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// excluded returns true if p is in Exclude.
+func (w *Watcher) excluded(p string) bool {
+	for _, name := range w.Exclude {
+		if filepath.Clean(name) == filepath.Clean(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed polls the watched files once and returns the paths created,
+// modified or removed since the previous poll in sorted order.
+func (w *Watcher) Changed() []string {
+	changed := []string{}
+	stamps := w.scan()
+	for p, s := range stamps {
+		if prev, ok := w.stamps[p]; ok == false || prev != s {
+			changed = append(changed, p)
+		}
+	}
+	for p := range w.stamps {
+		if _, ok := stamps[p]; ok == false {
+			changed = append(changed, p)
+		}
+	}
+	w.stamps = stamps
+	sort.Strings(changed)
+	return changed
+}
+
+// Watch polls every Interval calling fn with the changed paths
+// whenever something changes. It returns when done is closed.
+func (w *Watcher) Watch(done <-chan bool, fn func(changed []string)) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if changed := w.Changed(); len(changed) > 0 {
+				fn(changed)
+			}
+		}
+	}
+}
+
+// DataFiles returns the local files read by ResolveData for a set
+// of key/value pairs, values with a type prefix (e.g. "text:") and
+// URLs are skipped.
+func DataFiles(data map[string]string) []string {
+	prefixes := []string{TextPrefix, MarkdownPrefix, GomarkdownPrefix, FountainPrefix, JSONPrefix, "http://", "https://"}
+	files := []string{}
+	for _, val := range data {
+		isFile := true
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(val, prefix) {
+				isFile = false
+				break
+			}
+		}
+		if isFile {
+			files = append(files, val)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
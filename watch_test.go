@@ -0,0 +1,102 @@
+//
+// watch_test.go test routines for watch.go
+//
+// @author R. S. Doiel, <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2020, Caltech
+// All rights not granted herein are expressly reserved by Caltech
+//
+// Redistribution and use in source and binary forms, with or without modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice, this list of conditions and the following disclaimer in the documentation and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors may be used to endorse or promote products derived from this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+package mkpage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dName, err := ioutil.TempDir("", "mkpage-watch")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dName)
+	mdFName := path.Join(dName, "index.md")
+	outDir := path.Join(dName, "htdocs")
+	os.MkdirAll(outDir, 0775)
+	ioutil.WriteFile(mdFName, []byte("# Hello"), 0664)
+	tmplFName := path.Join(dName, "..", path.Base(dName)+".tmpl")
+	defer os.Remove(tmplFName)
+
+	w := new(Watcher)
+	w.Interval = 10 * time.Millisecond
+	w.Exclude = []string{outDir}
+	w.Add(dName, tmplFName)
+	if changed := w.Changed(); len(changed) != 0 {
+		t.Errorf("expected no changes, got %+v", changed)
+	}
+
+	// Modified, created and removed files are reported
+	ioutil.WriteFile(mdFName, []byte("# Hello World"), 0664)
+	ioutil.WriteFile(tmplFName, []byte("{{ .content }}"), 0664)
+	changed := w.Changed()
+	if len(changed) != 2 || strings.Contains(strings.Join(changed, " "), mdFName) == false || strings.Contains(strings.Join(changed, " "), tmplFName) == false {
+		t.Errorf("expected %s and %s to change, got %+v", mdFName, tmplFName, changed)
+	}
+	os.Remove(mdFName)
+	if changed := w.Changed(); len(changed) != 1 || changed[0] != mdFName {
+		t.Errorf("expected %s to be removed, got %+v", mdFName, changed)
+	}
+
+	// Excluded directories and dot files are ignored
+	ioutil.WriteFile(path.Join(outDir, "index.html"), []byte("<h1>Hello</h1>"), 0664)
+	ioutil.WriteFile(path.Join(dName, ".swp"), []byte("..."), 0664)
+	if changed := w.Changed(); len(changed) != 0 {
+		t.Errorf("expected no changes, got %+v", changed)
+	}
+
+	// Watch calls fn when something changes and returns when done
+	done := make(chan bool)
+	seen := make(chan []string, 1)
+	go w.Watch(done, func(changed []string) {
+		seen <- changed
+	})
+	ioutil.WriteFile(mdFName, []byte("# Hello Again"), 0664)
+	select {
+	case changed := <-seen:
+		if len(changed) != 1 || changed[0] != mdFName {
+			t.Errorf("expected %s to change, got %+v", mdFName, changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected Watch to report a change")
+	}
+	close(done)
+}
+
+func TestDataFiles(t *testing.T) {
+	data := map[string]string{
+		"content":   "index.md",
+		"nav":       "nav.md",
+		"title":     "text:My Page",
+		"footer":    "markdown:*Thanks*",
+		"weather":   "https://forecast.weather.gov/MapClick.php",
+		"structure": `json:{"a": 1}`,
+	}
+	files := DataFiles(data)
+	if strings.Join(files, " ") != "index.md nav.md" {
+		t.Errorf("expected index.md and nav.md, got %+v", files)
+	}
+}
@@ -20,11 +20,39 @@
 package mkpage
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LiveReloadPath is the Server-Sent Events endpoint browsers
+	// listen to for reload notifications.
+	LiveReloadPath = "/_live-reload"
+
+	// LiveReloadScript is injected into HTML pages served with
+	// live reload, it reloads the page when notified by LiveReloadPath.
+	LiveReloadScript = `<script>
+(function () {
+    if (window.EventSource === undefined) {
+        return;
+    }
+    var es = new EventSource("/_live-reload");
+    es.addEventListener("reload", function () {
+        window.location.reload();
+    });
+}());
+</script>
+`
 )
 
 // IsDotPath checks to see if a path is requested with a dot file (e.g. docs/.git/* or docs/.htaccess)
@@ -73,3 +101,285 @@ func StaticRouter(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// InjectLiveReload adds LiveReloadScript to an HTML page before the
+// closing body tag, or at the end if there isn't one.
+func InjectLiveReload(src []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(src), []byte("</body>"))
+	if i < 0 {
+		return append(src, []byte(LiveReloadScript)...)
+	}
+	out := make([]byte, 0, len(src)+len(LiveReloadScript))
+	out = append(out, src[0:i]...)
+	out = append(out, []byte(LiveReloadScript)...)
+	return append(out, src[i:]...)
+}
+
+// LiveReload notifies browsers when files in a document root change.
+// Browsers listen on LiveReloadPath using Server-Sent Events.
+type LiveReload struct {
+	// Interval is how often the document root is polled for changes
+	Interval time.Duration
+
+	mu      sync.Mutex
+	clients map[chan string]bool
+}
+
+// NewLiveReload creates a LiveReload polling once a second.
+func NewLiveReload() *LiveReload {
+	return &LiveReload{
+		Interval: time.Second,
+		clients:  map[chan string]bool{},
+	}
+}
+
+// Notify sends a reload event naming the changed files to each
+// connected browser.
+func (lr *LiveReload) Notify(changed []string) {
+	msg := strings.Join(changed, " ")
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- msg:
+		default:
+			// NOTE: a reload is already pending for this client
+		}
+	}
+}
+
+// Watch polls docRoot notifying browsers of changes until done
+// is closed.
+func (lr *LiveReload) Watch(docRoot string, done <-chan bool) {
+	NewWatcher(lr.Interval, docRoot).Watch(done, lr.Notify)
+}
+
+// ServeHTTP implements the Server-Sent Events endpoint.
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if ok == false {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	client := make(chan string, 1)
+	lr.mu.Lock()
+	lr.clients[client] = true
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-client:
+			fmt.Fprintf(w, "event: reload\ndata: %s\n\n", msg)
+			flusher.Flush()
+		}
+	}
+}
+
+// Handler serves LiveReloadPath and injects LiveReloadScript into
+// HTML pages served by next.
+func (lr *LiveReload) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == LiveReloadPath {
+			lr.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// NOTE: ranges of a page would no longer line up with
+		// the injected script so the whole page is served.
+		r.Header.Del("Range")
+		iw := &injectWriter{ResponseWriter: w}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// injectWriter buffers successful HTML responses so LiveReloadScript
+// can be added, other responses are passed through.
+type injectWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	inject      bool
+	buf         bytes.Buffer
+}
+
+func (iw *injectWriter) WriteHeader(status int) {
+	if iw.wroteHeader {
+		return
+	}
+	iw.wroteHeader = true
+	iw.status = status
+	if status == http.StatusOK && strings.HasPrefix(iw.Header().Get("Content-Type"), "text/html") {
+		iw.inject = true
+		return
+	}
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *injectWriter) Write(p []byte) (int, error) {
+	if iw.wroteHeader == false {
+		if iw.Header().Get("Content-Type") == "" {
+			iw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		iw.WriteHeader(http.StatusOK)
+	}
+	if iw.inject {
+		return iw.buf.Write(p)
+	}
+	return iw.ResponseWriter.Write(p)
+}
+
+// finish writes a buffered HTML response with LiveReloadScript added.
+func (iw *injectWriter) finish() {
+	if iw.inject == false {
+		return
+	}
+	src := InjectLiveReload(iw.buf.Bytes())
+	iw.Header().Set("Content-Length", strconv.Itoa(len(src)))
+	iw.ResponseWriter.WriteHeader(iw.status)
+	iw.ResponseWriter.Write(src)
+}
+
+// renderedPage is a page rendered by RenderHandler along with the
+// modification times of its inputs and the hashes of the site's
+// configuration and data when it was rendered.
+type renderedPage struct {
+	stamp string
+	src   []byte
+}
+
+// renderSource returns the document which renders to the page
+// requested by urlPath, e.g. /foo.html is rendered from foo.md,
+// foo.mmark or foo.fountain. An empty string is returned if there
+// is an HTML file or no matching document.
+func (site *Site) renderSource(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") {
+		p = path.Join(p, "index.html")
+	}
+	if path.Ext(p) != ".html" {
+		return ""
+	}
+	fName := filepath.Join(site.ContentDir, filepath.FromSlash(p))
+	if info, err := os.Stat(fName); err == nil && info.IsDir() == false {
+		return ""
+	}
+	base := strings.TrimSuffix(fName, ".html")
+	for _, ext := range []string{".md", ".mmark", ".fountain"} {
+		if info, err := os.Stat(base + ext); err == nil && info.IsDir() == false {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// modStamp describes the modification time and size of files,
+// missing files and URLs are recorded as such.
+func modStamp(names ...string) string {
+	parts := []string{}
+	for _, name := range names {
+		if info, err := os.Stat(name); err == nil {
+			parts = append(parts, fmt.Sprintf("%s %s %d", name, info.ModTime().Format(time.RFC3339Nano), info.Size()))
+		} else {
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// RenderHandler serves requests for HTML pages which don't exist in
+// ContentDir by rendering the matching .md, .mmark or .fountain document
+// with the site's templates and data the same way Build does. Rendered
+// pages are cached until the document, its templates, data files,
+// the site's data or configuration are modified. Other requests are passed to next.
+func (site *Site) RenderHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := site.renderSource(r.URL.Path)
+		if p == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		src, err := site.renderPage(p)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Can't render %s", r.URL.Path), http.StatusInternalServerError)
+			ResponseLogger(r, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(src)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(src)
+		}
+	})
+}
+
+// renderPage renders the document at p or returns the cached
+// rendering if its inputs haven't been modified.
+func (site *Site) renderPage(p string) ([]byte, error) {
+	src, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	configType, frontMatterSrc, _, err := SplitFrontMatterWithError(src)
+	if err != nil {
+		return nil, err
+	}
+	frontMatter, err := ProcessorConfig(configType, frontMatterSrc)
+	if err != nil {
+		return nil, err
+	}
+	templateSources := site.templateList(p, frontMatter)
+	names := append([]string{p}, templateSources...)
+	stamp := strings.Join([]string{
+		modStamp(append(names, DataFiles(site.Data)...)...),
+		DataDep + " " + hashValue(site.Data),
+		ConfigDep + " " + hashValue(MergeConfig(Config, ConfigOverrides)),
+	}, "\n")
+	templateKey := strings.Join(templateSources, ":")
+	templateStamp := modStamp(templateSources...)
+
+	site.mu.Lock()
+	if site.rendered == nil {
+		site.rendered = map[string]*renderedPage{}
+	}
+	cached, ok := site.rendered[p]
+	if ok == true && cached.stamp == stamp {
+		site.mu.Unlock()
+		return cached.src, nil
+	}
+	// NOTE: only templates whose files have changed are reassembled
+	if site.templateStamps == nil {
+		site.templateStamps = map[string]string{}
+	}
+	if site.templateStamps[templateKey] != templateStamp {
+		delete(site.templates, templateKey)
+		site.templateStamps[templateKey] = templateStamp
+	}
+	site.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := site.executeDocument(&buf, p, strings.TrimSuffix(p, filepath.Ext(p))+".html", frontMatter, templateSources, nil); err != nil {
+		return nil, err
+	}
+	site.mu.Lock()
+	site.rendered[p] = &renderedPage{stamp: stamp, src: buf.Bytes()}
+	site.mu.Unlock()
+	return buf.Bytes(), nil
+}
@@ -19,7 +19,15 @@
 package mkpage
 
 import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsDotPath(t *testing.T) {
@@ -45,3 +53,169 @@ func TestIsDotPath(t *testing.T) {
 		}
 	}
 }
+
+func TestInjectLiveReload(t *testing.T) {
+	src := InjectLiveReload([]byte("<html><body><h1>Hi</h1></BODY></html>"))
+	expected := "<html><body><h1>Hi</h1>" + LiveReloadScript + "</BODY></html>"
+	if string(src) != expected {
+		t.Errorf("expected %q, got %q", expected, src)
+	}
+	src = InjectLiveReload([]byte("<h1>Hi</h1>"))
+	if string(src) != "<h1>Hi</h1>"+LiveReloadScript {
+		t.Errorf("expected script appended, got %q", src)
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	dName, err := ioutil.TempDir("", "mkpage-ws")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dName)
+	ioutil.WriteFile(path.Join(dName, "index.html"), []byte("<html><body>Hello</body></html>"), 0664)
+	ioutil.WriteFile(path.Join(dName, "site.css"), []byte("body { color: red; }"), 0664)
+
+	lr := NewLiveReload()
+	ts := httptest.NewServer(lr.Handler(http.FileServer(http.Dir(dName))))
+	defer ts.Close()
+
+	// HTML pages get the script, other files are unchanged
+	for p, expected := range map[string]string{
+		"/index.html": "<html><body>Hello" + LiveReloadScript + "</body></html>",
+		"/site.css":   "body { color: red; }",
+	} {
+		resp, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Errorf("GET %s, %s", p, err)
+			continue
+		}
+		src, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(src) != expected {
+			t.Errorf("GET %s expected %q, got %q", p, expected, src)
+		}
+		if resp.ContentLength != int64(len(expected)) {
+			t.Errorf("GET %s expected Content-Length %d, got %d", p, len(expected), resp.ContentLength)
+		}
+	}
+
+	// Browsers listening on LiveReloadPath receive a reload event
+	resp, err := http.Get(ts.URL + LiveReloadPath)
+	if err != nil {
+		t.Errorf("GET %s, %s", LiveReloadPath, err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", contentType)
+	}
+	events := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "event: ") {
+				events <- strings.TrimPrefix(scanner.Text(), "event: ")
+				return
+			}
+		}
+	}()
+	// NOTE: Notify until the client has registered
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			if event != "reload" {
+				t.Errorf("expected reload event, got %q", event)
+			}
+			return
+		case <-ticker.C:
+			lr.Notify([]string{"index.html"})
+		case <-deadline:
+			t.Errorf("expected a reload event")
+			return
+		}
+	}
+}
+
+func TestRenderHandler(t *testing.T) {
+	dName, err := ioutil.TempDir("", "mkpage-ws")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dName)
+	mdFName := path.Join(dName, "draft.md")
+	ioutil.WriteFile(mdFName, []byte("---\ntitle: A Draft\n---\n\nFirst version\n"), 0664)
+	ioutil.WriteFile(path.Join(dName, "page.html"), []byte("<p>Static page</p>"), 0664)
+
+	site := new(Site)
+	site.ContentDir = dName
+	ts := httptest.NewServer(site.RenderHandler(http.FileServer(http.Dir(dName))))
+	defer ts.Close()
+
+	get := func(p string) (int, string) {
+		resp, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Errorf("GET %s, %s", p, err)
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		src, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(src)
+	}
+
+	status, src := get("/draft.html")
+	if status != 200 || strings.Contains(src, "<title>A Draft</title>") == false || strings.Contains(src, "First version") == false {
+		t.Errorf("expected draft.md rendered, got %d %s", status, src)
+	}
+
+	// Existing HTML and missing pages are left to the file server
+	if status, src := get("/page.html"); status != 200 || src != "<p>Static page</p>" {
+		t.Errorf("expected page.html unchanged, got %d %s", status, src)
+	}
+	if status, _ := get("/missing.html"); status != 404 {
+		t.Errorf("expected 404 for missing.html, got %d", status)
+	}
+
+	// A modified document is rendered again
+	ioutil.WriteFile(mdFName, []byte("---\ntitle: A Draft\n---\n\nThe second version\n"), 0664)
+	status, src = get("/draft.html")
+	if status != 200 || strings.Contains(src, "The second version") == false {
+		t.Errorf("expected updated draft.md rendered, got %d %s", status, src)
+	}
+}
+
+func TestRenderHandlerCache(t *testing.T) {
+	dName, err := ioutil.TempDir("", "mkpage-ws")
+	if err != nil {
+		t.Errorf("Can't create temp directory, %s", err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dName)
+	ioutil.WriteFile(path.Join(dName, "index.md"), []byte("# Home\n"), 0664)
+	tmplFName := path.Join(dName, "site.tmpl")
+	ioutil.WriteFile(tmplFName, []byte(`<p>{{ .greeting }}</p>`), 0664)
+
+	site := new(Site)
+	site.ContentDir = dName
+	site.Templates = []string{tmplFName}
+	site.Data = map[string]string{"greeting": "text:Hello"}
+	render := func(expected string) {
+		src, err := site.renderPage(path.Join(dName, "index.md"))
+		if err != nil || string(src) != expected {
+			t.Errorf("expected %q, got %q, %v", expected, src, err)
+		}
+	}
+	render("<p>Hello</p>")
+
+	// Changing the site's data renders the page again
+	site.Data["greeting"] = "text:Hi there"
+	render("<p>Hi there</p>")
+
+	// Changing a template reassembles it
+	ioutil.WriteFile(tmplFName, []byte(`<div>{{ .greeting }}</div>`), 0664)
+	render("<div>Hi there</div>")
+}